@@ -0,0 +1,213 @@
+// Package auth authenticates API callers and checks what they may do.
+//
+// Design decision hidden: How callers prove who they are and how their
+// permissions are expressed. A caller presents either an API key from
+// a key file or a JWT bearer token signed with HS256 or RS256, and is
+// granted a set of scopes such as catalog:read. Transports ask for a
+// scope per request and never see keys, tokens or signatures, so a new
+// credential type (mTLS, OIDC discovery) would only touch this
+// package.
+package auth
+
+import (
+	"bufio"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Scopes granted to callers. Each is independent: writing does not
+// imply reading.
+const (
+	ScopeRead    = "catalog:read"
+	ScopeWrite   = "catalog:write"
+	ScopeMetrics = "metrics:read"
+)
+
+// ErrUnauthenticated is wrapped by errors for requests that carry no
+// valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Name is the key's name or the token's subject.
+	Name string
+
+	// Method is how the caller authenticated: "api-key" or "jwt".
+	Method string
+
+	Scopes []string
+}
+
+// HasScope reports whether p was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Config selects the credentials an Authenticator accepts. Any
+// combination may be set; setting none disables authentication.
+type Config struct {
+	// APIKeysFile is the path of a key file; see New.
+	APIKeysFile string
+
+	// JWTSecret verifies HS256 tokens.
+	JWTSecret []byte
+
+	// JWTPublicKey verifies RS256 tokens.
+	JWTPublicKey *rsa.PublicKey
+
+	// JWTIssuer and JWTAudience, if set, must match a token's iss and
+	// aud claims.
+	JWTIssuer   string
+	JWTAudience string
+}
+
+// ConfigFromEnv reads the authentication configuration:
+//
+//	AUTH_API_KEYS_FILE        key file path
+//	AUTH_JWT_SECRET           HS256 shared secret
+//	AUTH_JWT_PUBLIC_KEY_FILE  PEM RSA public key (or certificate) for RS256
+//	AUTH_JWT_ISSUER           required iss claim
+//	AUTH_JWT_AUDIENCE         required aud claim
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		APIKeysFile: os.Getenv("AUTH_API_KEYS_FILE"),
+		JWTIssuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		JWTAudience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+	if secret := os.Getenv("AUTH_JWT_SECRET"); secret != "" {
+		cfg.JWTSecret = []byte(secret)
+	}
+	if path := os.Getenv("AUTH_JWT_PUBLIC_KEY_FILE"); path != "" {
+		key, err := readPublicKey(path)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.JWTPublicKey = key
+	}
+	return cfg, nil
+}
+
+// enabled reports whether cfg accepts any credential.
+func (cfg Config) enabled() bool {
+	return cfg.APIKeysFile != "" || cfg.JWTSecret != nil || cfg.JWTPublicKey != nil
+}
+
+// Authenticator checks the credentials of requests. A nil
+// Authenticator admits every request. Safe for concurrent use.
+type Authenticator struct {
+	keys map[[sha256.Size]byte]*Principal // by hash of the key
+	cfg  Config
+}
+
+// New creates an Authenticator accepting the credentials cfg selects,
+// or returns nil if it selects none.
+//
+// The key file has one API key per line: a name, the key and a
+// comma-separated list of scopes, separated by whitespace. Blank lines
+// and lines starting with '#' are ignored. For example:
+//
+//	# name    key                               scopes
+//	loadtest  6f1c0d3e9a8b4f2e7d5c1a9b8e3f4d2c  catalog:read
+//	importer  a93e5b7c1d2f4e6a8b0c9d7e5f3a1b2c  catalog:read,catalog:write
+func New(cfg Config) (*Authenticator, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	a := &Authenticator{keys: make(map[[sha256.Size]byte]*Principal), cfg: cfg}
+	if cfg.APIKeysFile != "" {
+		if err := a.loadKeys(cfg.APIKeysFile); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *Authenticator) loadKeys(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return fmt.Errorf("auth: %s:%d: want a name, a key and scopes", path, line)
+		}
+		hash := sha256.Sum256([]byte(fields[1]))
+		if _, dup := a.keys[hash]; dup {
+			return fmt.Errorf("auth: %s:%d: duplicate key", path, line)
+		}
+		a.keys[hash] = &Principal{Name: fields[0], Method: "api-key", Scopes: strings.Split(fields[2], ",")}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("auth: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Authenticate identifies the caller from an API key or, failing
+// that, from an Authorization header holding a bearer token. Either
+// may be empty. Errors wrap ErrUnauthenticated.
+func (a *Authenticator) Authenticate(apiKey, authorization string) (*Principal, error) {
+	if apiKey != "" {
+		// Keys are looked up by hash, so the lookup's timing says
+		// nothing about how much of a guessed key was right.
+		if p, ok := a.keys[sha256.Sum256([]byte(apiKey))]; ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: an X-API-Key header or bearer token is required", ErrUnauthenticated)
+	}
+	return a.verifyJWT(strings.TrimSpace(token))
+}
+
+// readPublicKey loads an RSA public key from a PEM file holding a
+// PKIX or PKCS #1 public key or an X.509 certificate.
+func readPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("auth: %s: no PEM data", path)
+	}
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("auth: %s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("auth: %s: not an RSA public key", path)
+	}
+	return rsaKey, nil
+}
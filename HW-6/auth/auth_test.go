@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var secret = []byte("sekret")
+
+// sign returns a compact JWT for claims, signed with alg: HS256 with
+// secret, RS256 with key, or "none".
+func sign(t *testing.T, alg string, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	seg := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := seg(map[string]string{"alg": alg, "typ": "JWT"}) + "." + seg(claims)
+	var sig []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func keyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewDisabled(t *testing.T) {
+	a, err := New(Config{})
+	if a != nil || err != nil {
+		t.Fatalf("New with no credentials = %v, %v; want nil, nil", a, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, err := New(Config{APIKeysFile: keyFile(t, "# comment\n\nreader rkey catalog:read\nwriter wkey catalog:read,catalog:write\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate("wkey", "")
+	if err != nil || p.Name != "writer" || p.Method != "api-key" || !p.HasScope(ScopeWrite) || p.HasScope(ScopeMetrics) {
+		t.Fatalf("Authenticate(wkey) = %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate("nope", ""); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("unknown key: %v", err)
+	}
+	if _, err := a.Authenticate("", ""); !errors.Is(err, ErrUnauthenticated) {
+		t.Fatalf("no credentials: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"missing scopes": "reader rkey\n",
+		"duplicate key":  "a key catalog:read\nb key catalog:read\n",
+	} {
+		if _, err := New(Config{APIKeysFile: keyFile(t, content)}); err == nil {
+			t.Errorf("%s: key file accepted", name)
+		}
+	}
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a, err := New(Config{JWTSecret: secret, JWTPublicKey: &rsaKey.PublicKey, JWTIssuer: "me", JWTAudience: "search"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	valid := func() map[string]any {
+		return map[string]any{"sub": "alice", "iss": "me", "aud": []string{"search", "other"}, "exp": now + 60, "scope": "catalog:read"}
+	}
+	with := func(k string, v any) map[string]any {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"HS256", sign(t, "HS256", nil, valid()), true},
+		{"RS256", sign(t, "RS256", rsaKey, valid()), true},
+		{"scp list", sign(t, "HS256", nil, with("scp", []string{"metrics:read"})), true},
+		{"within clock skew", sign(t, "HS256", nil, with("exp", now-10)), true},
+		{"expired", sign(t, "HS256", nil, with("exp", now-120)), false},
+		{"no expiry", sign(t, "HS256", nil, with("exp", nil)), false},
+		{"not yet valid", sign(t, "HS256", nil, with("nbf", now+120)), false},
+		{"wrong issuer", sign(t, "HS256", nil, with("iss", "you")), false},
+		{"wrong audience", sign(t, "HS256", nil, with("aud", "billing")), false},
+		{"alg none", sign(t, "none", nil, valid()), false},
+		{"other RSA key", sign(t, "RS256", other, valid()), false},
+		{"tampered", sign(t, "HS256", nil, valid())[:20] + "x" + sign(t, "HS256", nil, valid())[21:], false},
+		{"malformed", "a.b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate("", "Bearer "+tt.token)
+			if tt.ok != (err == nil) {
+				t.Fatalf("Authenticate = %+v, %v; want ok=%v", p, err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthenticated) {
+				t.Fatalf("error %v does not wrap ErrUnauthenticated", err)
+			}
+			if tt.ok && (p.Name != "alice" || p.Method != "jwt") {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+
+	// An RS256-only Authenticator must not accept HS256 tokens signed
+	// with its public key as the secret.
+	rsOnly, _ := New(Config{JWTPublicKey: &rsaKey.PublicKey})
+	if _, err := rsOnly.Authenticate("", "Bearer "+sign(t, "HS256", nil, valid())); err == nil {
+		t.Fatal("an HS256 token was accepted without a secret configured")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	a, err := New(Config{APIKeysFile: keyFile(t, "reader rkey catalog:read\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen *Principal
+	h := a.Require(CatalogScope, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+	}))
+	tests := []struct {
+		method, key, authorization string
+		code                       int
+		challenge                  string
+	}{
+		{"GET", "rkey", "", http.StatusOK, ""},
+		{"POST", "rkey", "", http.StatusForbidden, `error="insufficient_scope", scope="catalog:write"`},
+		{"GET", "", "", http.StatusUnauthorized, `Bearer realm="product-search"`},
+		{"GET", "", "Bearer junk", http.StatusUnauthorized, `error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		seen = nil
+		r := httptest.NewRequest(tt.method, "/products", nil)
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.code || !strings.Contains(rec.Header().Get("WWW-Authenticate"), tt.challenge) {
+			t.Errorf("%s with key %q: %d, challenge %q", tt.method, tt.key, rec.Code, rec.Header().Get("WWW-Authenticate"))
+		}
+		if (tt.code == http.StatusOK) != (seen != nil) {
+			t.Errorf("%s with key %q: handler saw principal %v", tt.method, tt.key, seen)
+		}
+	}
+
+	var none *Authenticator
+	rec := httptest.NewRecorder()
+	none.Require(CatalogScope, http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("POST", "/products", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("nil Authenticator: %d, want the request passed through", rec.Code)
+	}
+}
+
+func TestAuthenticateConcurrently(t *testing.T) {
+	a, err := New(Config{APIKeysFile: keyFile(t, "reader rkey catalog:read\n"), JWTSecret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "Bearer " + sign(t, "HS256", nil, map[string]any{"sub": "bob", "exp": time.Now().Unix() + 60})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 500 {
+				if _, err := a.Authenticate("rkey", ""); err != nil {
+					t.Error(err)
+				}
+				if _, err := a.Authenticate("", token); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far a token's time claims may be off from ours.
+const clockSkew = 30 * time.Second
+
+// claims are the JWT claims the Authenticator reads. Scopes may be
+// given as a space-separated scope string (RFC 8693) or an scp list.
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	Scope     string   `json:"scope"`
+	Scp       scopes   `json:"scp"`
+}
+
+// audience is an aud claim, a string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	return stringOrList((*[]string)(a), b)
+}
+
+// scopes is an scp claim, a space-separated string or a list.
+type scopes []string
+
+func (s *scopes) UnmarshalJSON(b []byte) error {
+	if err := stringOrList((*[]string)(s), b); err != nil {
+		return err
+	}
+	if len(*s) == 1 {
+		*s = strings.Fields((*s)[0])
+	}
+	return nil
+}
+
+func stringOrList(dst *[]string, b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*dst = []string{one}
+		return nil
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// verifyJWT checks a compact-serialized token's signature and claims.
+// The algorithm must be one the Authenticator has a key for, so a
+// token cannot pick a weaker check than the one configured: "none" is
+// never accepted, nor HS256 signed with the RS256 public key.
+func (a *Authenticator) verifyJWT(token string) (*Principal, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: invalid token: %s", ErrUnauthenticated, reason)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch {
+	case header.Alg == "HS256" && a.cfg.JWTSecret != nil:
+		mac := hmac.New(sha256.New, a.cfg.JWTSecret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, invalid("bad signature")
+		}
+	case header.Alg == "RS256" && a.cfg.JWTPublicKey != nil:
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(a.cfg.JWTPublicKey, crypto.SHA256, digest[:], sig) != nil {
+			return nil, invalid("bad signature")
+		}
+	default:
+		return nil, invalid(fmt.Sprintf("unsupported algorithm %q", header.Alg))
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, invalid("malformed claims")
+	}
+	now := time.Now()
+	switch {
+	case c.ExpiresAt == nil:
+		return nil, invalid("no expiry")
+	case now.After(numericDate(*c.ExpiresAt).Add(clockSkew)):
+		return nil, invalid("expired")
+	case c.NotBefore != nil && now.Add(clockSkew).Before(numericDate(*c.NotBefore)):
+		return nil, invalid("not yet valid")
+	case a.cfg.JWTIssuer != "" && c.Issuer != a.cfg.JWTIssuer:
+		return nil, invalid("wrong issuer")
+	case a.cfg.JWTAudience != "" && !slices.Contains(c.Audience, a.cfg.JWTAudience):
+		return nil, invalid("wrong audience")
+	}
+
+	p := &Principal{Name: c.Subject, Method: "jwt", Scopes: strings.Fields(c.Scope)}
+	p.Scopes = append(p.Scopes, c.Scp...)
+	return p, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// numericDate converts a JWT NumericDate, seconds since the epoch.
+func numericDate(secs float64) time.Time {
+	return time.Unix(int64(secs), 0)
+}
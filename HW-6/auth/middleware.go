@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type principalKey struct{}
+
+// FromContext returns the caller authenticated by Require, or by
+// another transport that recorded it with NewContext, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// NewContext returns a copy of ctx carrying the authenticated caller p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// CatalogScope is the scope a catalog request needs: ScopeRead for
+// safe methods, ScopeWrite for the rest.
+func CatalogScope(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	}
+	return ScopeWrite
+}
+
+// Scope returns a scope function that asks for scope on every request.
+func Scope(scope string) func(*http.Request) string {
+	return func(*http.Request) string { return scope }
+}
+
+// Require wraps next so that it only serves callers granted the scope
+// that scope returns for the request. Others get a 401 if their
+// credentials are missing or invalid, or a 403 if they lack the scope,
+// with a WWW-Authenticate challenge as in RFC 6750. The caller is
+// available to next through FromContext. On a nil Authenticator,
+// Require returns next unchanged.
+func (a *Authenticator) Require(scope func(*http.Request) string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r.Header.Get("X-API-Key"), r.Header.Get("Authorization"))
+		if err != nil {
+			challenge := `Bearer realm="product-search"`
+			if r.Header.Get("Authorization") != "" {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		if need := scope(r); !p.HasScope(need) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="product-search", error="insufficient_scope", scope="`+need+`"`)
+			writeError(w, http.StatusForbidden, errors.New("scope "+need+" is required"))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
+
+// writeError sends a JSON error body like the handler package's.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultSize is the catalog size used when none is configured.
+const DefaultSize = 100000
+
+// Config describes the shape of a generated catalog. Two runs with the
+// same Config and seeds produce the same catalog.
+//
+// The skew fields are Zipf exponents: 0 draws uniformly, 1 is a
+// classic Zipf distribution, and larger values concentrate the
+// catalog on the highest-ranked entries.
+type Config struct {
+	// Size is the number of products, with IDs 1 through Size.
+	Size int
+
+	// Seed selects the random sequence.
+	Seed uint64
+
+	// CategorySkew distributes products over categories, ranked by
+	// how many seeds each has.
+	CategorySkew float64
+
+	// BrandSkew distributes a category's products over the brands its
+	// seeds carry, ranked by how many seeds carry each.
+	BrandSkew float64
+
+	// PopularitySkew distributes a brand's products in a category over
+	// its seeds, so a few seeds spawn most of the variants.
+	PopularitySkew float64
+
+	// PriceNoise is the standard deviation of the log-normal factor
+	// applied to a seed's price.
+	PriceNoise float64
+
+	// NameMutation is the probability that a variant's name gains a
+	// qualifier such as "Compact" or "(Pack of 3)".
+	NameMutation float64
+
+	// DescriptionSentences is the most sentences drawn from the
+	// descriptions of seeds in the same category and appended to a
+	// product's description. A category whose seeds have no
+	// descriptions gains none.
+	DescriptionSentences int
+
+	// Workers is the number of goroutines generating and storing
+	// products; zero means GOMAXPROCS. It does not affect the catalog.
+	Workers int
+
+	// Owns selects the IDs stored; the rest are skipped. Nil stores
+	// every product. A shard sets it to keep only its own part of the
+	// catalog, which is otherwise generated as usual.
+	Owns func(id int) bool
+}
+
+// profiles are named starting points for common catalog shapes.
+var profiles = map[string]Config{
+	"default": {
+		Size:           DefaultSize,
+		Seed:           1,
+		CategorySkew:   0.5,
+		BrandSkew:      1,
+		PopularitySkew: 1,
+		PriceNoise:     0.1,
+		NameMutation:   0.5,
+	},
+	"uniform": {
+		Size:         DefaultSize,
+		Seed:         1,
+		NameMutation: 0.5,
+	},
+	"skewed": {
+		Size:           DefaultSize,
+		Seed:           1,
+		CategorySkew:   1.2,
+		BrandSkew:      2,
+		PopularitySkew: 1.5,
+		PriceNoise:     0.1,
+		NameMutation:   0.5,
+	},
+	"verbose": {
+		Size:                 DefaultSize,
+		Seed:                 1,
+		CategorySkew:         0.5,
+		BrandSkew:            1,
+		PopularitySkew:       1,
+		PriceNoise:           0.1,
+		NameMutation:         0.5,
+		DescriptionSentences: 8,
+	},
+}
+
+// DefaultConfig returns the "default" profile.
+func DefaultConfig() Config {
+	return profiles["default"]
+}
+
+// ConfigFromEnv builds a Config from the GEN_PROFILE environment
+// variable ("default", "uniform", "skewed" or "verbose"), then applies
+// any of GEN_SIZE, GEN_SEED, GEN_CATEGORY_SKEW, GEN_BRAND_SKEW,
+// GEN_POPULARITY_SKEW, GEN_PRICE_NOISE, GEN_NAME_MUTATION,
+// GEN_DESCRIPTION_SENTENCES and GEN_WORKERS on top of it.
+func ConfigFromEnv() (Config, error) {
+	name := os.Getenv("GEN_PROFILE")
+	if name == "" {
+		name = "default"
+	}
+	cfg, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown GEN_PROFILE %q", name)
+	}
+
+	ints := []struct {
+		env string
+		dst *int
+	}{
+		{"GEN_SIZE", &cfg.Size},
+		{"GEN_DESCRIPTION_SENTENCES", &cfg.DescriptionSentences},
+		{"GEN_WORKERS", &cfg.Workers},
+	}
+	for _, v := range ints {
+		if s := os.Getenv(v.env); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q", v.env, s)
+			}
+			*v.dst = n
+		}
+	}
+	if s := os.Getenv("GEN_SEED"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid GEN_SEED %q", s)
+		}
+		cfg.Seed = n
+	}
+	floats := []struct {
+		env string
+		dst *float64
+	}{
+		{"GEN_CATEGORY_SKEW", &cfg.CategorySkew},
+		{"GEN_BRAND_SKEW", &cfg.BrandSkew},
+		{"GEN_POPULARITY_SKEW", &cfg.PopularitySkew},
+		{"GEN_PRICE_NOISE", &cfg.PriceNoise},
+		{"GEN_NAME_MUTATION", &cfg.NameMutation},
+	}
+	for _, v := range floats {
+		if s := os.Getenv(v.env); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q", v.env, s)
+			}
+			*v.dst = f
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+// validate rejects settings the generator cannot honour.
+func (c Config) validate() error {
+	switch {
+	case c.Size < 0:
+		return fmt.Errorf("catalog size must not be negative")
+	case c.CategorySkew < 0 || c.BrandSkew < 0 || c.PopularitySkew < 0:
+		return fmt.Errorf("skew exponents must not be negative")
+	case c.PriceNoise < 0:
+		return fmt.Errorf("price noise must not be negative")
+	case c.NameMutation < 0 || c.NameMutation > 1:
+		return fmt.Errorf("name mutation must be a probability between 0 and 1")
+	case c.DescriptionSentences < 0:
+		return fmt.Errorf("description sentences must not be negative")
+	case c.Workers < 0:
+		return fmt.Errorf("workers must not be negative")
+	}
+	return nil
+}
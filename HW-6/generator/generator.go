@@ -1,53 +1,278 @@
 // Package generator populates the product store from seed data.
 //
 // Design decision hidden: The strategy for expanding seed products
-// into a full catalog. Currently uses variant numbering with slight
-// price adjustments. Could be changed to use random generation,
-// Markov chains, or any other expansion strategy without affecting
-// the store, search, or handler modules.
+// into a full catalog. Each product is drawn from a seeded random
+// source: a category by Zipfian rank, then a brand among those the
+// category's seeds carry, then a seed of that brand by Zipfian
+// popularity. The variant keeps the seed's category and brand, so the
+// brand always fits the product; the seed's price gets log-normal
+// noise, and its name and description may be mutated. The shape is
+// set by a Config (see ConfigFromEnv). Product i depends only on the
+// Config, the seeds and i, so a catalog is reproducible and is
+// generated by parallel workers.
+// Could be changed to use Markov chains or any other expansion
+// strategy without affecting the store, search, or handler modules.
 package generator
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"log"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"product-search/model"
 	"product-search/seeddata"
 	"product-search/store"
 )
 
-const TotalProducts = 100000
+// Qualifiers that name mutation adds to a seed's name.
+var (
+	namePrefixes = []string{
+		"Premium", "Classic", "Compact", "Deluxe", "Essential", "Pro",
+		"Eco", "Ultra", "Mini", "Travel", "Signature", "Everyday",
+	}
+	nameSuffixes = []string{
+		"Black", "White", "Silver", "Blue", "Red", "Green",
+		"Large", "Small", "XL", "Limited Edition", "2nd Gen", "Plus",
+	}
+)
 
-// Populate fills the store with TotalProducts products derived from seeds.
-func Populate(s *store.ProductStore) {
-	seeds := seeddata.Load()
-	numSeeds := len(seeds)
+// Generator expands seeds into synthetic products.
+type Generator struct {
+	cfg        Config
+	categories []category
+	pick       zipf // over categories
+}
 
-	log.Printf("Generating %d products from %d real product seeds...\n", TotalProducts, numSeeds)
+// category holds the seeds of one category and what is drawn from it.
+type category struct {
+	brands    []brand  // most seeds first
+	sentences []string // from every seed description
+	pickBrand zipf
+}
 
-	for i := 1; i <= TotalProducts; i++ {
-		seed := seeds[(i-1)%numSeeds]
+// brand holds the seeds of one brand within a category.
+type brand struct {
+	seeds    []seeddata.SeedProduct // in seed order
+	pickSeed zipf
+}
+
+// New prepares a Generator for seeds. Categories, and the brands within
+// each, are ranked by how many seeds they have, ties broken by first
+// appearance, so the ranking and hence the catalog depend only on the
+// seed order.
+func New(seeds []seeddata.SeedProduct, cfg Config) *Generator {
+	byCategory := make(map[string][]seeddata.SeedProduct)
+	var order []string
+	for _, s := range seeds {
+		if _, ok := byCategory[s.Category]; !ok {
+			order = append(order, s.Category)
+		}
+		byCategory[s.Category] = append(byCategory[s.Category], s)
+	}
+	slices.SortStableFunc(order, func(a, b string) int {
+		return cmp.Compare(len(byCategory[b]), len(byCategory[a]))
+	})
+
+	g := &Generator{cfg: cfg, pick: newZipf(len(order), cfg.CategorySkew)}
+	for _, name := range order {
+		g.categories = append(g.categories, newCategory(byCategory[name], cfg))
+	}
+	return g
+}
 
-		// Variant numbering: first cycle uses original name, subsequent
-		// cycles append "Edition N" to differentiate.
-		variantNum := (i-1)/numSeeds + 1
-		name := seed.Name
-		if variantNum > 1 {
-			name = fmt.Sprintf("%s Edition %d", seed.Name, variantNum)
+// newCategory groups the seeds of one category by brand.
+func newCategory(seeds []seeddata.SeedProduct, cfg Config) category {
+	var c category
+	byBrand := make(map[string]int) // brand → index in c.brands
+	for _, s := range seeds {
+		i, ok := byBrand[s.Brand]
+		if !ok {
+			i = len(c.brands)
+			byBrand[s.Brand] = i
+			c.brands = append(c.brands, brand{})
 		}
+		c.brands[i].seeds = append(c.brands[i].seeds, s)
+		c.sentences = append(c.sentences, sentences(s.Description)...)
+	}
+	slices.SortStableFunc(c.brands, func(a, b brand) int {
+		return cmp.Compare(len(b.seeds), len(a.seeds))
+	})
+	for i := range c.brands {
+		c.brands[i].pickSeed = newZipf(len(c.brands[i].seeds), cfg.PopularitySkew)
+	}
+	c.pickBrand = newZipf(len(c.brands), cfg.BrandSkew)
+	return c
+}
+
+// Product returns the product with the given ID.
+func (g *Generator) Product(id int) model.Product {
+	r := rand.New(rand.NewPCG(g.cfg.Seed, uint64(id)))
+
+	c := &g.categories[g.pick.draw(r)]
+	b := &c.brands[c.pickBrand.draw(r)]
+	seed := b.seeds[b.pickSeed.draw(r)]
+
+	name := seed.Name
+	if r.Float64() < g.cfg.NameMutation {
+		name = mutateName(r, name)
+	}
+
+	desc := seed.Description
+	if g.cfg.DescriptionSentences > 0 && len(c.sentences) > 0 {
+		extra := make([]string, 0, g.cfg.DescriptionSentences+1)
+		extra = append(extra, desc)
+		for range r.IntN(g.cfg.DescriptionSentences + 1) {
+			extra = append(extra, c.sentences[r.IntN(len(c.sentences))])
+		}
+		desc = strings.Join(extra, " ")
+	}
+
+	price := seed.Price * math.Exp(r.NormFloat64()*g.cfg.PriceNoise)
+
+	return model.Product{
+		ID:          id,
+		Name:        name,
+		Category:    seed.Category,
+		Description: desc,
+		Brand:       seed.Brand,
+		Price:       math.Round(price*100) / 100,
+	}
+}
+
+// Population is a catalog being written to a store by Start. Safe for
+// concurrent use.
+type Population struct {
+	total  int
+	loaded atomic.Int64
+	done   chan struct{}
+}
+
+// Progress returns how many products have been stored, out of how
+// many, and whether population has finished. A population stopped
+// early is finished with fewer than total loaded.
+func (p *Population) Progress() (loaded, total int, done bool) {
+	select {
+	case <-p.done:
+		done = true
+	default:
+	}
+	return int(p.loaded.Load()), p.total, done
+}
+
+// Wait blocks until population has finished.
+func (p *Population) Wait() {
+	<-p.done
+}
+
+// populateBatch is the number of consecutive IDs a worker claims at a
+// time.
+const populateBatch = 1024
+
+// Start fills the store with cfg.Size products derived from seeds in
+// the background, using cfg.Workers goroutines. Products become
+// visible as they are stored, in no particular order. IDs already in
+// the store are left as they are, so starting again with the same
+// Config resumes a population that was stopped. Seeds are loaded
+// before Start returns. Cancelling ctx stops the workers after their
+// current batch; the population then finishes early.
+func Start(ctx context.Context, s store.Store, cfg Config) *Population {
+	seeds := seeddata.Load()
+	g := New(seeds, cfg)
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-		// Small price drift per variant to simulate real-world variation.
-		price := seed.Price * (1.0 + float64(variantNum-1)*0.01)
+	p := &Population{total: cfg.Size, done: make(chan struct{})}
+	log.Printf("Generating %d products from %d real product seeds (seed %d, %d workers)...\n",
+		cfg.Size, len(seeds), cfg.Seed, workers)
+	start := time.Now()
 
-		s.Put(model.Product{
-			ID:          i,
-			Name:        name,
-			Category:    seed.Category,
-			Description: seed.Description,
-			Brand:       seed.Brand,
-			Price:       price,
+	var next atomic.Int64 // first ID of the next unclaimed batch, minus one
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				first := int(next.Add(populateBatch)) - populateBatch + 1
+				if first > cfg.Size {
+					return
+				}
+				last := min(first+populateBatch-1, cfg.Size)
+				for id := first; id <= last; id++ {
+					if cfg.Owns != nil && !cfg.Owns(id) {
+						continue
+					}
+					if _, _, err := s.Insert(g.Product(id)); err != nil {
+						log.Fatalf("Failed to store product %d: %v", id, err)
+					}
+				}
+				p.advance(last - first + 1)
+			}
 		})
 	}
+	go func() {
+		wg.Wait()
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if loaded := int(p.loaded.Load()); loaded < cfg.Size {
+			log.Printf("Product generation stopped: %d of %d products loaded in %s\n",
+				loaded, cfg.Size, elapsed)
+		} else {
+			log.Printf("Product catalog ready: %d products loaded in %s\n", s.Count(), elapsed)
+		}
+		close(p.done)
+	}()
+	return p
+}
+
+// advance records n more stored products, logging every tenth of the
+// way.
+func (p *Population) advance(n int) {
+	loaded := int(p.loaded.Add(int64(n)))
+	if before := loaded - n; loaded*10/p.total > before*10/p.total {
+		log.Printf("Generating products: %d%% (%d/%d)\n", loaded*100/p.total, loaded, p.total)
+	}
+}
+
+// Populate fills the store like Start and waits for it to finish.
+func Populate(s store.Store, cfg Config) {
+	Start(context.Background(), s, cfg).Wait()
+}
+
+// mutateName adds a qualifier before or after name, or both.
+func mutateName(r *rand.Rand, name string) string {
+	switch r.IntN(4) {
+	case 0:
+		return namePrefixes[r.IntN(len(namePrefixes))] + " " + name
+	case 1:
+		return name + " - " + nameSuffixes[r.IntN(len(nameSuffixes))]
+	case 2:
+		return fmt.Sprintf("%s (Pack of %d)", name, 2+r.IntN(11))
+	default:
+		prefix := namePrefixes[r.IntN(len(namePrefixes))]
+		suffix := nameSuffixes[r.IntN(len(nameSuffixes))]
+		return prefix + " " + name + " - " + suffix
+	}
+}
 
-	log.Printf("Product catalog ready: %d products loaded\n", s.Count())
+// sentences splits a description into sentences.
+func sentences(desc string) []string {
+	var out []string
+	for _, s := range strings.SplitAfter(desc, ". ") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
 }
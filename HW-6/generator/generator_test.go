@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"product-search/model"
+	"product-search/seeddata"
+	"product-search/store"
+)
+
+func testSeeds(t *testing.T) []seeddata.SeedProduct {
+	t.Helper()
+	seeds, err := seeddata.Embedded{}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seeds
+}
+
+// TestProductKeepsSeedBrand checks that every variant carries the
+// brand of a seed it could have come from, so brand filters and facets
+// agree with product names.
+func TestProductKeepsSeedBrand(t *testing.T) {
+	seeds := testSeeds(t)
+	for _, profile := range []string{"default", "uniform", "skewed"} {
+		g := New(seeds, profiles[profile])
+		for id := 1; id <= 5000; id++ {
+			p := g.Product(id)
+			found := false
+			for _, s := range seeds {
+				if strings.Contains(p.Name, s.Name) && s.Brand == p.Brand && s.Category == p.Category {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("%s: product %d %q has brand %q, which no seed of that name carries",
+					profile, id, p.Name, p.Brand)
+			}
+		}
+	}
+}
+
+func TestProductIsReproducible(t *testing.T) {
+	seeds := testSeeds(t)
+	cfg := profiles["verbose"]
+	a, b := New(seeds, cfg), New(seeds, cfg)
+	for id := 1; id <= 1000; id++ {
+		if pa, pb := a.Product(id), b.Product(id); pa != pb {
+			t.Fatalf("product %d differs between runs: %+v vs %+v", id, pa, pb)
+		}
+	}
+	cfg.Seed++
+	if New(seeds, cfg).Product(1) == a.Product(1) {
+		t.Error("a different seed gave the same product")
+	}
+}
+
+// TestBrandSkew checks that BrandSkew shapes how a category's products
+// spread over its brands, whatever the number of seeds of each.
+func TestBrandSkew(t *testing.T) {
+	seeds := []seeddata.SeedProduct{
+		{Name: "Buds", Category: "Audio", Brand: "Acme", Price: 50},
+		{Name: "Buds Pro", Category: "Audio", Brand: "Acme", Price: 90},
+		{Name: "Buds Max", Category: "Audio", Brand: "Acme", Price: 120},
+		{Name: "Speaker", Category: "Audio", Brand: "Bolt", Price: 70},
+		{Name: "Headphones", Category: "Audio", Brand: "Crest", Price: 150},
+	}
+	tests := []struct {
+		skew     float64
+		min, max float64 // share of products that are Acme's
+	}{
+		{0, 0.28, 0.39},
+		{1, 0.50, 0.58},
+		{3, 0.82, 0.90},
+	}
+	for _, tt := range tests {
+		cfg := profiles["default"]
+		cfg.BrandSkew = tt.skew
+		g := New(seeds, cfg)
+		const n = 10000
+		acme := 0
+		for id := 1; id <= n; id++ {
+			p := g.Product(id)
+			if p.Brand == "Acme" {
+				acme++
+			}
+		}
+		if share := float64(acme) / n; share < tt.min || share > tt.max {
+			t.Errorf("brand skew %g: %.2f of products are Acme's, want %.2f to %.2f",
+				tt.skew, share, tt.min, tt.max)
+		}
+	}
+
+	t.Setenv("GEN_BRAND_SKEW", "2.5")
+	if cfg, err := ConfigFromEnv(); err != nil || cfg.BrandSkew != 2.5 {
+		t.Errorf("GEN_BRAND_SKEW=2.5 gave %v, %v", cfg.BrandSkew, err)
+	}
+	t.Setenv("GEN_BRAND_SKEW", "-1")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("a negative brand skew was accepted")
+	}
+}
+
+// TestProductWithoutDescriptions checks that a category whose seeds
+// have no descriptions, which a CSV seed file may supply, still
+// generates when description sentences are asked for.
+func TestProductWithoutDescriptions(t *testing.T) {
+	seeds := []seeddata.SeedProduct{
+		{Name: "Plain Mug", Category: "Kitchen", Brand: "Acme", Price: 9},
+		{Name: "Plain Bowl", Category: "Kitchen", Brand: "Acme", Price: 12},
+	}
+	g := New(seeds, profiles["verbose"])
+	for id := 1; id <= 100; id++ {
+		if p := g.Product(id); p.Description != "" {
+			t.Fatalf("product %d has description %q", id, p.Description)
+		}
+	}
+}
+
+// stopAfter is a store that cancels a population once n products have
+// been inserted, as a shutdown part way through a first boot does.
+type stopAfter struct {
+	store.Store
+	n      atomic.Int64
+	cancel context.CancelFunc
+}
+
+func (s *stopAfter) Insert(p model.Product) (model.Product, bool, error) {
+	if s.n.Add(-1) == 0 {
+		s.cancel()
+	}
+	return s.Store.Insert(p)
+}
+
+// TestStartResumes stops a population part way, then starts it again
+// on the same store, as a restart after an interrupted first boot does.
+func TestStartResumes(t *testing.T) {
+	t.Setenv("SEED_SOURCE", "embedded")
+	cfg := profiles["default"]
+	cfg.Size = 20 * populateBatch
+	cfg.Workers = 2
+
+	s := store.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopping := &stopAfter{Store: s, cancel: cancel}
+	stopping.n.Store(3 * populateBatch)
+	Start(ctx, stopping, cfg).Wait()
+	if n := s.Count(); n == 0 || n == cfg.Size {
+		t.Fatalf("stopped population stored %d products", n)
+	}
+
+	// A product written meanwhile is kept rather than regenerated.
+	g := New(testSeeds(t), cfg)
+	edited := g.Product(1)
+	edited.Name = "Edited"
+	s.Put(edited)
+
+	Start(context.Background(), s, cfg).Wait()
+	if s.Count() != cfg.Size {
+		t.Fatalf("resumed population stored %d products, want %d", s.Count(), cfg.Size)
+	}
+	for id := 2; id <= cfg.Size; id++ {
+		if got, _ := s.Get(id); got != g.Product(id) {
+			t.Fatalf("product %d = %+v, want %+v", id, got, g.Product(id))
+		}
+	}
+	if got, _ := s.Get(1); got.Name != "Edited" {
+		t.Fatalf("product 1 was overwritten: %+v", got)
+	}
+}
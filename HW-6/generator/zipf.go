@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// zipf draws ranks 0..n-1 with probability proportional to
+// 1/(rank+1)^s. Unlike rand.Zipf it accepts any s ≥ 0, including the
+// uniform s = 0.
+type zipf []float64 // cumulative weights
+
+func newZipf(n int, s float64) zipf {
+	z := make(zipf, n)
+	total := 0.0
+	for k := range z {
+		total += 1 / math.Pow(float64(k+1), s)
+		z[k] = total
+	}
+	return z
+}
+
+func (z zipf) draw(r *rand.Rand) int {
+	x := r.Float64() * z[len(z)-1]
+	return min(sort.SearchFloat64s(z, x), len(z)-1)
+}
@@ -0,0 +1,76 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"product-search/auth"
+)
+
+// AuthOptions returns the server options making every ProductSearch
+// RPC require catalog:read credentials from a, sent as x-api-key or
+// authorization metadata as over HTTP. The caller is available to the
+// RPC and later interceptors through auth.FromContext. The health
+// service stays open. A nil a returns none.
+func AuthOptions(a *auth.Authenticator) []grpc.ServerOption {
+	if a == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := authorize(ctx, a, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authorize(ss.Context(), a, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, contextStream{ss, ctx})
+		}),
+	}
+}
+
+// authorize checks the credentials in ctx's metadata for a call to
+// method and returns ctx carrying the caller.
+func authorize(ctx context.Context, a *auth.Authenticator, method string) (context.Context, error) {
+	if strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	p, err := a.Authenticate(first("x-api-key"), first("authorization"))
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !p.HasScope(auth.ScopeRead) {
+		return nil, status.Error(codes.PermissionDenied, "scope "+auth.ScopeRead+" is required")
+	}
+	return auth.NewContext(ctx, p), nil
+}
+
+// contextStream is a server stream whose context is replaced.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,71 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"product-search/auth"
+	"product-search/pb"
+)
+
+func TestAuthOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("reader rkey catalog:read\nmonitor mkey metrics:read\n"), 0o600)
+	a, err := auth.New(auth.Config{APIKeysFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dial(t, AuthOptions(a)...)
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no credentials", context.Background(), codes.Unauthenticated},
+		{"unknown key", withKey("nope"), codes.Unauthenticated},
+		{"without catalog:read", withKey("mkey"), codes.PermissionDenied},
+		{"reader", withKey("rkey"), codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.GetProduct(tt.ctx, &pb.GetProductRequest{Id: 1})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("GetProduct: %v, want %v", err, tt.want)
+			}
+			stream, err := client.Export(tt.ctx, &pb.ExportRequest{})
+			for err == nil {
+				_, err = stream.Recv()
+			}
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Export: %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthLeavesHealthOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(path, []byte("reader rkey catalog:read\n"), 0o600)
+	a, _ := auth.New(auth.Config{APIKeysFile: path})
+
+	res, err := healthpb.NewHealthClient(serve(t, AuthOptions(a)...)).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health check without credentials: %v, %v", res, err)
+	}
+}
@@ -0,0 +1,88 @@
+package grpcserver
+
+import (
+	"product-search/model"
+	"product-search/pb"
+	"product-search/search"
+)
+
+// product converts a catalog product to its message.
+func product(p model.Product) *pb.Product {
+	return &pb.Product{
+		Id:          int64(p.ID),
+		Name:        p.Name,
+		Category:    p.Category,
+		Description: p.Description,
+		Brand:       p.Brand,
+		Price:       p.Price,
+	}
+}
+
+// strategy converts a search strategy to its enum value.
+func strategy(s search.Strategy) pb.Strategy {
+	switch s {
+	case search.StrategyIndex:
+		return pb.Strategy_STRATEGY_INDEX
+	case search.StrategyScan:
+		return pb.Strategy_STRATEGY_SCAN
+	}
+	return pb.Strategy_STRATEGY_UNSPECIFIED
+}
+
+// searchResponse converts a search result to its message.
+func searchResponse(r search.Result) *pb.SearchResponse {
+	out := &pb.SearchResponse{
+		Hits:            make([]*pb.Hit, len(r.Products)),
+		TotalFound:      int64(r.TotalFound),
+		ProductsChecked: int64(r.Checked),
+		Strategy:        strategy(r.Strategy),
+		NextCursor:      r.NextCursor,
+		Cached:          r.Cached,
+		TimedOut:        r.TimedOut,
+		SearchTime:      r.SearchTime,
+	}
+	for i, h := range r.Products {
+		out.Hits[i] = &pb.Hit{Product: product(h.Product), Score: h.Score}
+		if h.Highlight != nil {
+			out.Hits[i].Highlight = make(map[string]*pb.Fragment, len(h.Highlight))
+			for field, f := range h.Highlight {
+				out.Hits[i].Highlight[field] = fragment(f)
+			}
+		}
+	}
+	if r.Facets != nil {
+		out.Facets = facets(r.Facets)
+	}
+	for _, c := range r.Corrections {
+		out.Corrections = append(out.Corrections, &pb.Correction{Term: c.Term, Corrected: c.Corrected})
+	}
+	return out
+}
+
+func fragment(f search.Fragment) *pb.Fragment {
+	out := &pb.Fragment{Text: f.Text, Matches: make([]*pb.Span, len(f.Matches))}
+	for i, m := range f.Matches {
+		out.Matches[i] = &pb.Span{Start: int32(m[0]), End: int32(m[1])}
+	}
+	return out
+}
+
+func facets(f *search.Facets) *pb.Facets {
+	out := &pb.Facets{
+		Categories: counts(f.Categories),
+		Brands:     counts(f.Brands),
+		Prices:     make([]*pb.PriceBucket, len(f.Prices)),
+	}
+	for i, b := range f.Prices {
+		out.Prices[i] = &pb.PriceBucket{Min: b.Min, Max: b.Max, Count: int64(b.Count)}
+	}
+	return out
+}
+
+func counts(m map[string]int) map[string]int64 {
+	out := make(map[string]int64, len(m))
+	for k, n := range m {
+		out[k] = int64(n)
+	}
+	return out
+}
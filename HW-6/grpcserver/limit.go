@@ -0,0 +1,81 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"product-search/limit"
+	"product-search/pb"
+)
+
+// routes maps each RPC to the HTTP route it mirrors, whose limits it
+// shares.
+var routes = map[string]string{
+	pb.ProductSearch_Search_FullMethodName:     "/products/search",
+	pb.ProductSearch_GetProduct_FullMethodName: "/products/{id}",
+	pb.ProductSearch_Export_FullMethodName:     "/products/export",
+}
+
+// LimitOptions returns the server options applying l to the
+// ProductSearch RPCs, each under the limits of the HTTP route it
+// mirrors. A client over its rate gets ResourceExhausted, and an RPC
+// shed because the route is overloaded gets Unavailable; both carry a
+// retry-after header in seconds. Clients are told apart by the caller
+// AuthOptions found, so these options go after those. A nil l returns
+// none.
+func LimitOptions(l *limit.Limits) []grpc.ServerOption {
+	if l == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			done, err := admit(ctx, l, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			done, err := admit(ss.Context(), l, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			defer done()
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// admit asks l to admit a call to method, converting a rejection to a
+// gRPC status.
+func admit(ctx context.Context, l *limit.Limits, method string) (func(), error) {
+	route, ok := routes[method]
+	if !ok {
+		return func() {}, nil
+	}
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	done, err := l.Admit(route, limit.Client(ctx, addr))
+	var rej *limit.Rejection
+	if !errors.As(err, &rej) {
+		return done, err
+	}
+	code := codes.ResourceExhausted
+	if rej.Overloaded {
+		code = codes.Unavailable
+	}
+	secs := (rej.RetryAfter + time.Second - 1) / time.Second
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(secs))))
+	return nil, status.Error(code, rej.Error())
+}
@@ -0,0 +1,87 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"product-search/limit"
+	"product-search/pb"
+)
+
+func TestLimitsApplyToRPCs(t *testing.T) {
+	l := limit.New(limit.Config{Routes: map[string]limit.Policy{
+		"/products/search": {Rate: 1, Burst: 2},
+		"/products/export": {Rate: 1, Burst: 1},
+	}})
+	client := dial(t, LimitOptions(l)...)
+	ctx := context.Background()
+
+	for i := range 2 {
+		if _, err := client.Search(ctx, &pb.SearchRequest{Query: "widget"}); err != nil {
+			t.Fatalf("search %d of the burst: %v", i+1, err)
+		}
+	}
+	var header metadata.MD
+	_, err := client.Search(ctx, &pb.SearchRequest{Query: "widget"}, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted || len(header.Get("retry-after")) == 0 {
+		t.Fatalf("search over the rate: %v, header %v; want ResourceExhausted with retry-after", err, header)
+	}
+	if _, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: 1}); err != nil {
+		t.Fatalf("another route: %v", err)
+	}
+
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		stream, err := client.Export(ctx, &pb.ExportRequest{})
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		if status.Code(err) != want {
+			t.Fatalf("export %d: %v, want %v", i+1, err, want)
+		}
+	}
+}
+
+// TestLimitsShareBudgetWithHTTP holds an HTTP search in flight and
+// checks a gRPC search is shed against the same MaxInFlight.
+func TestLimitsShareBudgetWithHTTP(t *testing.T) {
+	l := limit.New(limit.Config{Routes: map[string]limit.Policy{"/products/search": {MaxInFlight: 1}}})
+	client := dial(t, LimitOptions(l)...)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	httpSearch := l.Wrap("/products/search", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	}))
+	go httpSearch.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/products/search?q=widget", nil))
+	<-started
+
+	_, err := client.Search(context.Background(), &pb.SearchRequest{Query: "widget"})
+	close(release)
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("gRPC search while an HTTP one is in flight: %v, want Unavailable", err)
+	}
+
+	stream, err := client.Export(context.Background(), &pb.ExportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := stream.Recv(); err == nil; _, err = stream.Recv() {
+		n++
+	}
+	if n != 5 {
+		t.Fatalf("exported %d products, want 5", n)
+	}
+}
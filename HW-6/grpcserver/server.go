@@ -0,0 +1,178 @@
+// Package grpcserver serves the product catalog over gRPC, beside the
+// HTTP/JSON API of package handler.
+//
+// Design decision hidden: How the gRPC API defined in package pb maps
+// onto the search and store modules: message conversion, status codes,
+// deadlines and streaming. Both transports call the same modules and
+// neither knows of the other, so either can change or go away alone.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"product-search/model"
+	"product-search/pb"
+	"product-search/search"
+	"product-search/store"
+)
+
+// Server implements pb.ProductSearchServer.
+type Server struct {
+	pb.UnimplementedProductSearchServer
+	store         store.Store
+	engine        *search.Engine
+	health        *health.Server
+	loading       Loading
+	servePartial  bool
+	searchTimeout time.Duration // zero means no default deadline
+}
+
+// Loading reports the progress of the initial catalog load.
+type Loading interface {
+	Progress() (loaded, total int, done bool)
+}
+
+// New creates a Server with the given store and search engine.
+func New(s store.Store, e *search.Engine) *Server {
+	return &Server{store: s, engine: e, health: health.NewServer()}
+}
+
+// TrackLoading makes the RPCs answer UNAVAILABLE until l has loaded
+// the catalog, unless servePartial is set, in which case they serve
+// whatever has been loaded so far.
+func (s *Server) TrackLoading(l Loading, servePartial bool) {
+	s.loading, s.servePartial = l, servePartial
+}
+
+// SetSearchTimeout sets the deadline for searches whose call has none.
+// Zero means none.
+func (s *Server) SetSearchTimeout(d time.Duration) {
+	s.searchTimeout = d
+}
+
+// Register registers the ProductSearch service on g, along with the
+// standard gRPC health service reporting it as serving.
+func (s *Server) Register(g *grpc.Server) {
+	pb.RegisterProductSearchServer(g, s)
+	healthpb.RegisterHealthServer(g, s.health)
+}
+
+// Drain makes the health service report NOT_SERVING, so clients and
+// load balancers move away before the server stops. RPCs keep being
+// served.
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Search runs a search, like GET /products/search.
+func (s *Server) Search(ctx context.Context, in *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	req := search.Request{
+		Query: in.GetQuery(),
+		Filters: search.Filters{
+			Category: in.GetCategory(),
+			Brand:    in.GetBrand(),
+			MinPrice: in.MinPrice,
+			MaxPrice: in.MaxPrice,
+		},
+		Limit:     int(in.GetLimit()),
+		Offset:    int(in.GetOffset()),
+		Facets:    in.GetFacets(),
+		Fuzzy:     in.GetFuzzy(),
+		Highlight: in.GetHighlight(),
+	}
+	switch in.GetStrategy() {
+	case pb.Strategy_STRATEGY_UNSPECIFIED:
+	case pb.Strategy_STRATEGY_INDEX:
+		req.Strategy = search.StrategyIndex
+	case pb.Strategy_STRATEGY_SCAN:
+		req.Strategy = search.StrategyScan
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown search strategy %d", in.GetStrategy())
+	}
+	if token := in.GetCursor(); token != "" {
+		cursor, err := search.ParseCursor(token)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		req.Cursor = cursor
+	}
+
+	searchCtx := ctx
+	if _, ok := ctx.Deadline(); !ok && s.searchTimeout > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, s.searchTimeout)
+		defer cancel()
+	}
+	result, err := s.engine.Execute(searchCtx, req)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, status.FromContextError(ctxErr).Err() // the caller has gone
+	}
+	switch {
+	case errors.Is(err, search.ErrInvalidRequest):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return searchResponse(result), nil
+}
+
+// GetProduct returns one product, like GET /products/{id}.
+func (s *Server) GetProduct(ctx context.Context, in *pb.GetProductRequest) (*pb.Product, error) {
+	if err := s.checkLoaded(); err != nil {
+		return nil, err
+	}
+	if in.GetId() < 1 || in.GetId() > math.MaxInt {
+		return nil, status.Error(codes.InvalidArgument, "product id must be a positive integer")
+	}
+	p, ok := s.store.Get(int(in.GetId()))
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "product %d not found", in.GetId())
+	}
+	return product(p), nil
+}
+
+// Export streams every product in ascending ID order, like
+// GET /products/export.
+func (s *Server) Export(in *pb.ExportRequest, stream grpc.ServerStreamingServer[pb.Product]) error {
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	var sendErr error
+	s.store.Iterate(ctx, math.MinInt, math.MaxInt, func(p model.Product) bool {
+		sendErr = stream.Send(product(p))
+		return sendErr == nil
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// checkLoaded returns UNAVAILABLE while the catalog is still loading,
+// unless partial serving is enabled.
+func (s *Server) checkLoaded() error {
+	if s.servePartial || s.loading == nil {
+		return nil
+	}
+	if loaded, total, done := s.loading.Progress(); !done {
+		return status.Error(codes.Unavailable, fmt.Sprintf("product catalog is still loading (%d of %d products)", loaded, total))
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"product-search/model"
+	"product-search/pb"
+	"product-search/search"
+	"product-search/store"
+)
+
+// serve serves a catalog of a few products with opts over an in-memory
+// connection and returns a connection to it.
+func serve(t *testing.T, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	s := store.New()
+	for id := 1; id <= 5; id++ {
+		s.Put(model.Product{ID: id, Name: "Widget", Category: "tools", Price: 1})
+	}
+	return serveServer(t, New(s, search.New(s, search.StrategyIndex)), opts...)
+}
+
+// serveServer serves srv with opts over an in-memory connection and
+// returns a connection to it.
+func serveServer(t *testing.T, srv *Server, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	g := grpc.NewServer(opts...)
+	srv.Register(g)
+	lis := bufconn.Listen(1 << 20)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// dial is serve returning a ProductSearch client.
+func dial(t *testing.T, opts ...grpc.ServerOption) pb.ProductSearchClient {
+	t.Helper()
+	return pb.NewProductSearchClient(serve(t, opts...))
+}
+
+// catalogClient serves products 30, 10 and 20, stored in that order.
+func catalogClient(t *testing.T) (pb.ProductSearchClient, *Server) {
+	t.Helper()
+	s := store.New()
+	s.Put(model.Product{ID: 30, Name: "Trail Shoe", Category: "Shoes", Brand: "Acme", Price: 80})
+	s.Put(model.Product{ID: 10, Name: "Road Shoe", Category: "Shoes", Brand: "Bolt", Price: 120})
+	s.Put(model.Product{ID: 20, Name: "Desk Lamp", Category: "Home", Brand: "Acme", Price: 40})
+	srv := New(s, search.New(s, search.StrategyIndex))
+	return pb.NewProductSearchClient(serveServer(t, srv)), srv
+}
+
+func TestSearch(t *testing.T) {
+	client, _ := catalogClient(t)
+	ctx := context.Background()
+
+	res, err := client.Search(ctx, &pb.SearchRequest{Query: "shoe", Brand: "acme", Facets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TotalFound != 1 || len(res.Hits) != 1 || res.Hits[0].Product.Id != 30 || res.Strategy != pb.Strategy_STRATEGY_INDEX {
+		t.Fatalf("search = %v", res)
+	}
+	if res.Facets.GetBrands()["Acme"] != 1 {
+		t.Fatalf("facets = %v", res.Facets)
+	}
+
+	res, err = client.Search(ctx, &pb.SearchRequest{Query: "shoe", Limit: 1})
+	if err != nil || len(res.Hits) != 1 || res.NextCursor == "" {
+		t.Fatalf("first page = %v, %v", res, err)
+	}
+	next, err := client.Search(ctx, &pb.SearchRequest{Query: "shoe", Limit: 1, Cursor: res.NextCursor})
+	if err != nil || len(next.Hits) != 1 || next.Hits[0].Product.Id == res.Hits[0].Product.Id {
+		t.Fatalf("second page = %v, %v", next, err)
+	}
+
+	invalid := []*pb.SearchRequest{
+		{Query: "shoe", Cursor: "not-a-cursor"},
+		{Query: "shoe", Strategy: pb.Strategy(99)},
+		{Query: "(shoe"},
+		{Query: "shoe", Limit: -1},
+	}
+	for _, in := range invalid {
+		if _, err := client.Search(ctx, in); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("search %v: %v, want InvalidArgument", in, err)
+		}
+	}
+}
+
+func TestGetProduct(t *testing.T) {
+	client, _ := catalogClient(t)
+	ctx := context.Background()
+	p, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: 20})
+	if err != nil || p.Name != "Desk Lamp" || p.Price != 40 {
+		t.Fatalf("GetProduct(20) = %v, %v", p, err)
+	}
+	if _, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: 11}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetProduct(11): %v, want NotFound", err)
+	}
+	if _, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: 0}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetProduct(0): %v, want InvalidArgument", err)
+	}
+}
+
+func TestExportStreamsInIDOrder(t *testing.T) {
+	client, _ := catalogClient(t)
+	stream, err := client.Export(context.Background(), &pb.ExportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.Id)
+	}
+	if len(ids) != 3 || ids[0] != 10 || ids[1] != 20 || ids[2] != 30 {
+		t.Fatalf("exported %v, want [10 20 30]", ids)
+	}
+}
+
+// loading is a catalog load stopped part way.
+type loading struct{}
+
+func (loading) Progress() (int, int, bool) { return 1, 3, false }
+
+func TestUnavailableWhileLoading(t *testing.T) {
+	ctx := context.Background()
+	for _, partial := range []bool{false, true} {
+		client, srv := catalogClient(t)
+		srv.TrackLoading(loading{}, partial)
+
+		want := codes.Unavailable
+		if partial {
+			want = codes.OK
+		}
+		_, err := client.Search(ctx, &pb.SearchRequest{Query: "shoe"})
+		if status.Code(err) != want {
+			t.Errorf("partial=%v: search: %v, want %v", partial, err, want)
+		}
+		_, err = client.GetProduct(ctx, &pb.GetProductRequest{Id: 10})
+		if status.Code(err) != want {
+			t.Errorf("partial=%v: GetProduct: %v, want %v", partial, err, want)
+		}
+		stream, err := client.Export(ctx, &pb.ExportRequest{})
+		for err == nil {
+			_, err = stream.Recv()
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		if status.Code(err) != want {
+			t.Errorf("partial=%v: export: %v, want %v", partial, err, want)
+		}
+	}
+}
+
+// TestDrainReportsNotServing checks that Drain turns the health service
+// away while RPCs are still answered.
+func TestDrainReportsNotServing(t *testing.T) {
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 1})
+	srv := New(s, search.New(s, search.StrategyIndex))
+	conn := serveServer(t, srv)
+	health, client := healthpb.NewHealthClient(conn), pb.NewProductSearchClient(conn)
+	ctx := context.Background()
+
+	res, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health before draining: %v, %v", res, err)
+	}
+	srv.Drain()
+	res, err = health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || res.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("health while draining: %v, %v; want NOT_SERVING", res, err)
+	}
+	p, err := client.GetProduct(ctx, &pb.GetProductRequest{Id: 1})
+	if err != nil || p.Name != "Widget" {
+		t.Fatalf("GetProduct while draining = %v, %v", p, err)
+	}
+}
@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"product-search/model"
+	"product-search/shard"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+
+	// maxLineBytes bounds a single NDJSON line.
+	maxLineBytes = 1 << 20
+
+	// maxReportedErrors caps the per-line errors echoed back by a bulk
+	// import; the failed count still covers every line.
+	maxReportedErrors = 100
+
+	// maxAtomicProducts bounds the products an atomic import holds in
+	// memory until every line has been validated.
+	maxAtomicProducts = 100000
+)
+
+// errTooManyStaged stops an atomic import larger than maxAtomicProducts.
+var errTooManyStaged = fmt.Errorf(
+	"an atomic import may hold at most %d products; split it or drop atomic=true", maxAtomicProducts)
+
+// csvColumns is the CSV header written by export and the set of
+// columns understood by import.
+var csvColumns = []string{"id", "name", "category", "description", "brand", "price"}
+
+// lineError reports why one line of a bulk import was rejected.
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// bulkReport summarizes a bulk import.
+type bulkReport struct {
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Atomic   bool        `json:"atomic"`
+	Errors   []lineError `json:"errors,omitempty"`
+}
+
+func (rep *bulkReport) reject(line int, err error) {
+	rep.Failed++
+	if len(rep.Errors) < maxReportedErrors {
+		rep.Errors = append(rep.Errors, lineError{Line: line, Error: err.Error()})
+	}
+}
+
+// BulkImport handles POST /products/bulk[?format=ndjson|csv][&atomic=true]
+//
+// The body is streamed one product per NDJSON line or CSV row (after a
+// header row naming the columns). Each product is upserted; one without
+// an id gets the next free ID. By default valid lines are stored as
+// they arrive and invalid ones are reported. With atomic=true nothing is
+// stored unless every line is valid, and a failed store write rolls
+// back the lines already applied; such an import is held in memory
+// until then, so one of more than maxAtomicProducts products is a 413.
+// The format defaults to the request's Content-Type, then NDJSON.
+func (h *ProductHandler) BulkImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	format, err := bulkFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	atomic := r.URL.Query().Get("atomic") == "true"
+
+	// An import takes as long as its body does to upload and store;
+	// the server's read and write timeouts are sized for ordinary
+	// requests.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	rep := bulkReport{Atomic: atomic}
+	var staged []model.Product
+	err = decodeProducts(r.Body, format, func(line int, p model.Product, err error) error {
+		if err == nil {
+			err = validateBulkProduct(p)
+		}
+		if err != nil {
+			rep.reject(line, err)
+			return nil
+		}
+		if atomic {
+			if len(staged) == maxAtomicProducts {
+				return errTooManyStaged
+			}
+			staged = append(staged, p)
+			return nil
+		}
+		if err := h.upsert(p); err != nil {
+			var notOwned *shard.NotOwnedError
+			if errors.As(err, &notOwned) {
+				rep.reject(line, err)
+				return nil
+			}
+			return err
+		}
+		rep.Imported++
+		return nil
+	})
+
+	var syntaxErr *bulkSyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The stream can't be resynchronized after a malformed CSV
+		// record, so stop there and report what was done.
+		rep.reject(syntaxErr.line, syntaxErr.err)
+	case errors.Is(err, errTooManyStaged):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil:
+		writeStoreError(w, err)
+		return
+	}
+
+	if atomic {
+		if rep.Failed > 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, rep)
+			return
+		}
+		if err := h.applyAll(staged); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		rep.Imported = len(staged)
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// upsert stores p, assigning an ID if it has none.
+func (h *ProductHandler) upsert(p model.Product) error {
+	if p.ID == 0 {
+		_, _, err := h.store.Insert(p)
+		return err
+	}
+	return h.store.Put(p)
+}
+
+// applyAll stores every product, undoing the ones already written if
+// a write fails. Readers may observe the batch while it is applied.
+func (h *ProductHandler) applyAll(products []model.Product) error {
+	type undo struct {
+		id   int
+		prev *model.Product
+	}
+	var done []undo
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			u := done[i]
+			var err error
+			if u.prev != nil {
+				err = h.store.Put(*u.prev)
+			} else {
+				_, _, err = h.store.Delete(u.id)
+			}
+			if err != nil {
+				log.Printf("bulk import rollback of product %d failed: %v", u.id, err)
+			}
+		}
+	}
+
+	for _, p := range products {
+		if p.ID == 0 {
+			created, _, err := h.store.Insert(p)
+			if err != nil {
+				rollback()
+				return err
+			}
+			done = append(done, undo{id: created.ID})
+			continue
+		}
+		u := undo{id: p.ID}
+		if prev, ok := h.store.Get(p.ID); ok {
+			u.prev = &prev
+		}
+		if err := h.store.Put(p); err != nil {
+			rollback()
+			return err
+		}
+		done = append(done, u)
+	}
+	return nil
+}
+
+// Export handles GET /products/export[?format=ndjson|csv]
+//
+// It streams the whole catalog in ascending ID order.
+func (h *ProductHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatNDJSON
+	}
+
+	var write func(model.Product) error
+	var flush func() error
+	bw := bufio.NewWriterSize(w, 64*1024)
+	switch format {
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(bw)
+		write = func(p model.Product) error { return enc.Encode(p) }
+		flush = bw.Flush
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(bw)
+		cw.Write(csvColumns)
+		write = func(p model.Product) error { return cw.Write(productRecord(p)) }
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "format must be 'ndjson' or 'csv'")
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="products.`+format+`"`)
+
+	// An export streams for as long as the catalog takes to write, so
+	// it is not bound by the server's write timeout.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	n := 0
+	h.store.Iterate(r.Context(), math.MinInt, math.MaxInt, func(p model.Product) bool {
+		if err := write(p); err != nil {
+			return false // client went away
+		}
+		if n++; n%1000 == 0 {
+			if flush() != nil {
+				return false
+			}
+			rc.Flush()
+		}
+		return true
+	})
+	flush()
+}
+
+// bulkFormat picks the import format from the format parameter or the
+// Content-Type header.
+func bulkFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		if f != formatNDJSON && f != formatCSV {
+			return "", errors.New("format must be 'ndjson' or 'csv'")
+		}
+		return f, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return formatCSV, nil
+	default:
+		return formatNDJSON, nil
+	}
+}
+
+// bulkSyntaxError is a malformed record that ends the stream.
+type bulkSyntaxError struct {
+	line int
+	err  error
+}
+
+func (e *bulkSyntaxError) Error() string { return fmt.Sprintf("line %d: %v", e.line, e.err) }
+
+// decodeProducts streams products from r, calling fn for each line
+// with the decoded product or the reason it could not be decoded. It
+// stops at the first error returned by fn or at a malformed CSV
+// record, which is returned as a *bulkSyntaxError.
+func decodeProducts(r io.Reader, format string, fn func(line int, p model.Product, err error) error) error {
+	if format == formatCSV {
+		return decodeCSV(r, fn)
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLineBytes)
+	line := 0
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var p model.Product
+		dec := json.NewDecoder(strings.NewReader(sc.Text()))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&p)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("invalid JSON: %v", err)
+		case dec.More():
+			err = errors.New("invalid JSON: unexpected data after object")
+		}
+		if err := fn(line, p, err); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return &bulkSyntaxError{line: line + 1, err: err}
+	}
+	return nil
+}
+
+// decodeCSV is decodeProducts for CSV input with a header row.
+func decodeCSV(r io.Reader, fn func(line int, p model.Product, err error) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return &bulkSyntaxError{line: 1, err: fmt.Errorf("reading CSV header: %v", err)}
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for name := range cols {
+		if !slices.Contains(csvColumns, name) {
+			return &bulkSyntaxError{line: 1, err: fmt.Errorf("unknown CSV column %q", name)}
+		}
+	}
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			line := 0
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line, err = parseErr.StartLine, parseErr.Err
+			}
+			return &bulkSyntaxError{line: line, err: err}
+		}
+		line, _ := cr.FieldPos(0)
+		p, err := parseRecord(rec, cols)
+		if err := fn(line, p, err); err != nil {
+			return err
+		}
+	}
+}
+
+// parseRecord builds a product from a CSV record using the header's
+// column positions.
+func parseRecord(rec []string, cols map[string]int) (model.Product, error) {
+	field := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	p := model.Product{
+		Name:        field("name"),
+		Category:    field("category"),
+		Description: field("description"),
+		Brand:       field("brand"),
+	}
+	if v := field("id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid id %q", v)
+		}
+		p.ID = id
+	}
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid price %q", field("price"))
+	}
+	p.Price = price
+	return p, nil
+}
+
+// productRecord is the CSV form of p, in csvColumns order.
+func productRecord(p model.Product) []string {
+	return []string{
+		strconv.Itoa(p.ID),
+		p.Name,
+		p.Category,
+		p.Description,
+		p.Brand,
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+	}
+}
+
+// validateBulkProduct applies validateProduct plus the ID rule for
+// imported lines, where a missing ID means "assign one".
+func validateBulkProduct(p model.Product) error {
+	if p.ID < 0 {
+		return errors.New("id must be a positive integer")
+	}
+	return validateProduct(p)
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"product-search/model"
+	"product-search/search"
+	"product-search/store"
+)
+
+func TestBulkImportRejectsTrailingData(t *testing.T) {
+	mux, _, s := testCatalog(t)
+	body := `{"name":"Widget","category":"tools","brand":"Acme","price":5} garbage` + "\n" +
+		`{"name":"Gadget","category":"tools","brand":"Acme","price":7}   ` + "\n" +
+		`{"name":"Twice","category":"tools","brand":"Acme","price":1} {"name":"Again"}` + "\n"
+	w := do(mux, "POST", "/products/bulk", body)
+	var rep bulkReport
+	json.NewDecoder(w.Body).Decode(&rep)
+	if w.Code != http.StatusOK || rep.Imported != 1 || rep.Failed != 2 {
+		t.Fatalf("status %d, report %+v; want 1 imported and lines 1 and 3 rejected", w.Code, rep)
+	}
+	if rep.Errors[0].Line != 1 || rep.Errors[1].Line != 3 || s.Count() != 1 {
+		t.Fatalf("errors %+v, %d stored", rep.Errors, s.Count())
+	}
+}
+
+func TestBulkImportCapsAtomicImports(t *testing.T) {
+	mux, _, s := testCatalog(t)
+	var b strings.Builder
+	for i := range maxAtomicProducts + 1 {
+		fmt.Fprintf(&b, `{"name":"Widget %d","category":"tools","brand":"Acme","price":1}`+"\n", i)
+	}
+	w := do(mux, "POST", "/products/bulk?atomic=true", b.String())
+	if w.Code != http.StatusRequestEntityTooLarge || s.Count() != 0 {
+		t.Fatalf("status %d with %d stored; want 413 and nothing stored", w.Code, s.Count())
+	}
+}
+
+// decodeReport decodes a bulk import's report.
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) bulkReport {
+	t.Helper()
+	var rep bulkReport
+	if err := json.NewDecoder(w.Body).Decode(&rep); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+	return rep
+}
+
+// errorLines returns the lines a report rejected.
+func errorLines(rep bulkReport) []int {
+	var lines []int
+	for _, e := range rep.Errors {
+		lines = append(lines, e.Line)
+	}
+	return lines
+}
+
+func TestBulkImportReportsInvalidLines(t *testing.T) {
+	tests := []struct {
+		name, target, body string
+		imported           int
+		lines              []int
+	}{
+		{
+			name:   "ndjson",
+			target: "/products/bulk",
+			body: `{"id":7,"name":"Widget","category":"tools","price":5}` + "\n" +
+				`{"name":"Gadget","category":"tools","price":-1}` + "\n" +
+				"\n" +
+				`{"name":"Gizmo","category":"toys","price":3}` + "\n" +
+				`{"name":"Sprocket","colour":"red"}` + "\n" +
+				`{"id":-4,"name":"Cog","category":"parts","price":1}` + "\n" +
+				`not json` + "\n",
+			imported: 2,
+			lines:    []int{2, 5, 6, 7},
+		},
+		{
+			name:   "csv",
+			target: "/products/bulk?format=csv",
+			body: "price,name,category,id\n" +
+				"5,Widget,tools,7\n" +
+				"cheap,Gadget,tools,\n" +
+				"3,Gizmo,toys,\n" +
+				"1,,parts,\n" +
+				"1,Cog,parts,x\n",
+			imported: 2,
+			lines:    []int{3, 5, 6},
+		},
+	}
+	for _, tt := range tests {
+		mux, _, s := testCatalog(t)
+		w := do(mux, "POST", tt.target, tt.body)
+		rep := decodeReport(t, w)
+		if w.Code != http.StatusOK || rep.Imported != tt.imported || rep.Failed != len(tt.lines) {
+			t.Errorf("%s: status %d, report %+v", tt.name, w.Code, rep)
+			continue
+		}
+		if got := errorLines(rep); !slices.Equal(got, tt.lines) {
+			t.Errorf("%s: rejected lines %v, want %v", tt.name, got, tt.lines)
+		}
+		if p, ok := s.Get(7); !ok || p.Name != "Widget" || s.Count() != tt.imported {
+			t.Errorf("%s: stored %d products, product 7 = %+v", tt.name, s.Count(), p)
+		}
+	}
+}
+
+// TestBulkImportStopsAtMalformedCSV checks that a CSV stream that can't
+// be resynchronized keeps what came before and reports where it broke.
+func TestBulkImportStopsAtMalformedCSV(t *testing.T) {
+	tests := []struct {
+		name, body string
+		imported   int
+		line       int
+	}{
+		{"unknown column", "name,category,price,colour\nWidget,tools,5,red\n", 0, 1},
+		{"empty body", "", 0, 1},
+		{"bare quote", "name,category,price\nWidget,tools,5\nGad\"get,tools,5\nGizmo,toys,3\n", 1, 3},
+	}
+	for _, tt := range tests {
+		mux, _, s := testCatalog(t)
+		w := do(mux, "POST", "/products/bulk?format=csv", tt.body)
+		rep := decodeReport(t, w)
+		if w.Code != http.StatusOK || rep.Imported != tt.imported || s.Count() != tt.imported ||
+			!slices.Equal(errorLines(rep), []int{tt.line}) {
+			t.Errorf("%s: status %d, report %+v, %d stored", tt.name, w.Code, rep, s.Count())
+		}
+	}
+}
+
+func TestBulkImportAtomic(t *testing.T) {
+	existing := model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 5}
+	valid := `{"id":1,"name":"Widget v2","category":"tools","price":6}` + "\n" +
+		`{"name":"Gadget","category":"tools","price":7}` + "\n"
+
+	mux, _, s := testCatalog(t, existing)
+	w := do(mux, "POST", "/products/bulk?atomic=true", valid+`{"name":"","category":"tools"}`+"\n")
+	rep := decodeReport(t, w)
+	if w.Code != http.StatusUnprocessableEntity || rep.Imported != 0 || !rep.Atomic ||
+		!slices.Equal(errorLines(rep), []int{3}) {
+		t.Fatalf("atomic import with an invalid line: status %d, report %+v", w.Code, rep)
+	}
+	if p, _ := s.Get(1); p != existing || s.Count() != 1 {
+		t.Fatalf("a rejected atomic import changed the store: %+v, %d stored", p, s.Count())
+	}
+
+	w = do(mux, "POST", "/products/bulk?atomic=true", valid)
+	if rep := decodeReport(t, w); w.Code != http.StatusOK || rep.Imported != 2 {
+		t.Fatalf("valid atomic import: status %d, report %+v", w.Code, rep)
+	}
+	if p, _ := s.Get(1); p.Name != "Widget v2" || s.Count() != 2 {
+		t.Fatalf("after a valid atomic import: %+v, %d stored", p, s.Count())
+	}
+}
+
+// failingStore fails writes of products named "Boom", as a full disk
+// would part way through a batch.
+type failingStore struct {
+	store.Store
+}
+
+var errBoom = errors.New("disk full")
+
+func (s failingStore) Put(p model.Product) error {
+	if p.Name == "Boom" {
+		return errBoom
+	}
+	return s.Store.Put(p)
+}
+
+func (s failingStore) Insert(p model.Product) (model.Product, bool, error) {
+	if p.Name == "Boom" {
+		return p, false, errBoom
+	}
+	return s.Store.Insert(p)
+}
+
+// TestBulkImportRollsBackAtomic fails a store write part way through an
+// atomic import and checks the writes before it are undone.
+func TestBulkImportRollsBackAtomic(t *testing.T) {
+	existing := model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 5}
+	for _, boom := range []string{
+		`{"id":9,"name":"Boom","category":"tools","price":1}`,
+		`{"name":"Boom","category":"tools","price":1}`,
+	} {
+		s := failingStore{store.New()}
+		s.Put(existing)
+		mux := http.NewServeMux()
+		New(s, search.New(s, search.StrategyIndex)).RegisterRoutes(mux)
+
+		body := `{"id":1,"name":"Widget v2","category":"tools","price":6}` + "\n" +
+			`{"name":"Gadget","category":"tools","price":7}` + "\n" +
+			`{"id":5,"name":"Gizmo","category":"toys","price":3}` + "\n" +
+			boom + "\n"
+		w := do(mux, "POST", "/products/bulk?atomic=true", body)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status %d, want 500", boom, w.Code)
+		}
+		if p, _ := s.Get(1); p != existing || s.Count() != 1 {
+			t.Errorf("%s: after rollback product 1 = %+v with %d stored; want only the original",
+				boom, p, s.Count())
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"product-search/auth"
+	"product-search/limit"
+	"product-search/metrics"
+	"product-search/shard"
+)
+
+// shardHealthTimeout bounds how long /health waits for the shards.
+const shardHealthTimeout = 2 * time.Second
+
+// CoordinatorHandler serves the HTTP API of a sharding coordinator,
+// which holds no products itself. Searches are fanned out to every
+// shard and merged; reads and writes of a single product, including
+// its creation, are forwarded to the shard that owns it.
+type CoordinatorHandler struct {
+	coordinator   *shard.Coordinator
+	searchTimeout time.Duration // zero means no default deadline
+	metrics       *handlerMetrics
+	shardFailures *metrics.Counter
+	auth          *auth.Authenticator // nil admits everyone
+	limits        *limit.Limits       // nil limits nothing
+	draining      atomic.Bool
+}
+
+// NewCoordinator creates a CoordinatorHandler over c.
+func NewCoordinator(c *shard.Coordinator) *CoordinatorHandler {
+	h := &CoordinatorHandler{coordinator: c, metrics: newHandlerMetrics()}
+	h.shardFailures = h.metrics.registry.NewCounter("search_shard_failures_total",
+		"Searches a shard failed to answer, by shard.", "shard")
+	return h
+}
+
+// SetSearchTimeout sets the deadline for searches that don't give
+// their own timeout_ms. Zero means none, leaving each shard to apply
+// its own.
+func (h *CoordinatorHandler) SetSearchTimeout(d time.Duration) {
+	h.searchTimeout = d
+}
+
+// UseAuth requires credentials from a as ProductHandler.UseAuth does.
+// Forwarded product requests keep their credentials, which the owning
+// shard checks again.
+func (h *CoordinatorHandler) UseAuth(a *auth.Authenticator) {
+	h.auth = a
+}
+
+// UseLimits applies l to the coordinator's routes as
+// ProductHandler.UseLimits does.
+func (h *CoordinatorHandler) UseLimits(l *limit.Limits) {
+	h.limits = l
+}
+
+// Drain makes /health report the coordinator as draining; see
+// ProductHandler.Drain.
+func (h *CoordinatorHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// RegisterRoutes wires up the coordinator's HTTP endpoints, each
+// instrumented as in ProductHandler.RegisterRoutes.
+func (h *CoordinatorHandler) RegisterRoutes(mux *http.ServeMux) {
+	routes := []route{
+		{"/products", h.Products, auth.CatalogScope},
+		{"/products/{id}", h.Product, auth.CatalogScope},
+		{"/products/search", h.Search, auth.CatalogScope},
+		{"/health", h.Health, nil},
+		{"/health/live", h.Live, nil},
+	}
+	registerRoutes(mux, routes, h.metrics, h.auth, h.limits)
+}
+
+// Product handles /products/{id} by forwarding the request to the
+// shard owning the product.
+func (h *CoordinatorHandler) Product(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "product id must be a positive integer")
+		return
+	}
+	h.coordinator.Proxy(id).ServeHTTP(w, r)
+}
+
+// Products handles POST /products by forwarding the product to the
+// shard that owns its ID. A product without an ID goes to the shards in
+// turn, and the one that stores it assigns an ID from its partition.
+// The shard validates the product.
+func (h *CoordinatorHandler) Products(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	var p struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	if p.ID < 0 {
+		writeError(w, http.StatusBadRequest, "id must be a positive integer")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	h.coordinator.ProxyInsert(p.ID).ServeHTTP(w, r)
+}
+
+// Search handles GET /products/search with the parameters described at
+// ProductHandler.Search, querying every shard and merging their hits.
+// offset plus limit may be at most search.MaxLimit; deeper pages take
+// a cursor, which cannot be combined with an offset. If some shards
+// fail or miss the deadline the response covers the rest and is marked
+// partial, listing the failures under shards; if none answers it is a
+// 502.
+func (h *CoordinatorHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+
+	req, timeout, err := searchRequest(r.URL.Query(), h.searchTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := h.coordinator.Search(ctx, req)
+	if r.Context().Err() != nil {
+		return // the client has gone
+	}
+	var invalid *shard.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, invalid)
+		return
+	case errors.Is(err, shard.ErrNoShards):
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	case err != nil:
+		writeError(w, searchErrorStatus(err), err.Error())
+		return
+	}
+	h.metrics.observeSearch(result.Result)
+	for _, f := range result.Shards.Failures {
+		h.shardFailures.Inc(strconv.Itoa(f.Shard))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Health handles GET /health, checking every shard. The coordinator is
+// "healthy" when all shards are ready, "degraded" when some are, in
+// which case searches are partial, and "unavailable" with a 503 when
+// none is. Once draining it reports "draining" with a 503.
+func (h *CoordinatorHandler) Health(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), shardHealthTimeout)
+	defer cancel()
+	shards := h.coordinator.Health(ctx)
+	ready := 0
+	for _, s := range shards {
+		if s.Ready {
+			ready++
+		}
+	}
+	status, code := "healthy", http.StatusOK
+	switch ready {
+	case 0:
+		status, code = "unavailable", http.StatusServiceUnavailable
+	case len(shards):
+	default:
+		status = "degraded"
+	}
+	writeJSON(w, code, map[string]any{"status": status, "shards": shards})
+}
+
+// Live handles GET /health/live; see ProductHandler.Live.
+func (h *CoordinatorHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
@@ -2,68 +2,371 @@
 //
 // Design decision hidden: The transport protocol, URL structure,
 // response format, and error handling strategy. Currently serves
-// JSON over HTTP. Could be replaced with gRPC, GraphQL, or any
-// other transport without modifying the search or store modules.
+// JSON over HTTP; package grpcserver serves the same modules over
+// gRPC. Could be replaced with GraphQL or any other transport without
+// modifying the search or store modules.
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync/atomic"
+	"time"
 
+	"product-search/auth"
+	"product-search/limit"
 	"product-search/search"
 	"product-search/store"
 )
 
 // ProductHandler holds dependencies for HTTP handlers.
 type ProductHandler struct {
-	store *store.ProductStore
+	store         store.Store
+	engine        *search.Engine
+	loading       Loading
+	servePartial  bool
+	searchTimeout time.Duration // zero means no default deadline
+	metrics       *handlerMetrics
+	auth          *auth.Authenticator // nil admits everyone
+	limits        *limit.Limits       // nil limits nothing
+	draining      atomic.Bool
 }
 
-// New creates a ProductHandler with the given store.
-func New(s *store.ProductStore) *ProductHandler {
-	return &ProductHandler{store: s}
+// maxSearchTimeout caps the timeout_ms a search request may ask for.
+const maxSearchTimeout = time.Minute
+
+// Loading reports the progress of the initial catalog load.
+type Loading interface {
+	Progress() (loaded, total int, done bool)
+}
+
+// New creates a ProductHandler with the given store and search engine.
+func New(s store.Store, e *search.Engine) *ProductHandler {
+	h := &ProductHandler{store: s, engine: e, metrics: newHandlerMetrics()}
+	h.metrics.registerCatalog(h)
+	return h
+}
+
+// TrackLoading makes the handler report l's progress on /health until
+// the catalog is loaded. Meanwhile the product endpoints answer 503
+// unless servePartial is set, in which case they serve whatever has
+// been loaded so far.
+func (h *ProductHandler) TrackLoading(l Loading, servePartial bool) {
+	h.loading, h.servePartial = l, servePartial
+}
+
+// SetSearchTimeout sets the deadline for searches that don't give
+// their own timeout_ms. Zero means none.
+func (h *ProductHandler) SetSearchTimeout(d time.Duration) {
+	h.searchTimeout = d
+}
+
+// UseAuth makes every endpoint but the health checks require
+// credentials from a: catalog:read to read the catalog, catalog:write
+// to change it and metrics:read for /metrics. Call it before
+// RegisterRoutes.
+func (h *ProductHandler) UseAuth(a *auth.Authenticator) {
+	h.auth = a
+}
+
+// UseLimits applies l's rate limits and load shedding to the routes it
+// names. Call it before RegisterRoutes.
+func (h *ProductHandler) UseLimits(l *limit.Limits) {
+	h.limits = l
+}
+
+// Drain makes /health report the service as draining, so the load
+// balancer stops sending it traffic before it shuts down. Other
+// endpoints keep serving.
+func (h *ProductHandler) Drain() {
+	h.draining.Store(true)
 }
 
-// RegisterRoutes wires up all HTTP endpoints.
+// RegisterRoutes wires up all HTTP endpoints. Each is instrumented
+// and traced under its route pattern and, given UseAuth and
+// UseLimits, authenticated and limited. The metrics are served on
+// /metrics.
 func (h *ProductHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/products/search", h.Search)
-	mux.HandleFunc("/health", h.Health)
+	routes := []route{
+		{"/products", h.whenLoaded(h.Products), auth.CatalogScope},
+		{"/products/{id}", h.whenLoaded(h.Product), auth.CatalogScope},
+		{"/products/bulk", h.whenLoaded(h.BulkImport), auth.CatalogScope},
+		{"/products/export", h.whenLoaded(h.Export), auth.CatalogScope},
+		{"/products/search", h.whenLoaded(h.Search), auth.CatalogScope},
+		{"/products/suggest", h.whenLoaded(h.Suggest), auth.CatalogScope},
+		{"/products/search/cache", h.CacheStats, auth.CatalogScope},
+		{"/health", h.Health, nil},
+		{"/health/live", h.Live, nil},
+	}
+	registerRoutes(mux, routes, h.metrics, h.auth, h.limits)
+}
+
+// route is an endpoint and the scope it requires; a nil scope makes it
+// public.
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+	scope   func(*http.Request) string
+}
+
+// registerRoutes registers routes on mux, authenticated by a, limited
+// by l, traced and instrumented by m, along with m's /metrics endpoint.
+func registerRoutes(mux *http.ServeMux, routes []route, m *handlerMetrics, a *auth.Authenticator, l *limit.Limits) {
+	for _, rt := range routes {
+		next := l.Wrap(rt.pattern, rt.handler)
+		if rt.scope != nil {
+			next = a.Require(rt.scope, next)
+		}
+		mux.HandleFunc(rt.pattern, m.instrument(rt.pattern, traced(rt.pattern, next)))
+	}
+	mux.Handle("/metrics", a.Require(auth.Scope(auth.ScopeMetrics), m.registry))
+}
+
+// whenLoaded rejects requests to next with 503 while the catalog is
+// still loading, unless partial serving is enabled.
+func (h *ProductHandler) whenLoaded(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.servePartial && h.loading != nil {
+			if _, _, done := h.loading.Progress(); !done {
+				w.Header().Set("Retry-After", "5")
+				writeError(w, http.StatusServiceUnavailable, "product catalog is still loading")
+				return
+			}
+		}
+		next(w, r)
+	}
 }
 
 // Search handles GET /products/search?q={query}
+//
+// q is written in the search query language, e.g.
+// brand:apple AND (laptop OR tablet) -refurbished price:<500. A
+// malformed query gets a 400 whose position is the byte offset of the
+// error in q.
+//
+// Filters category, brand, min_price and max_price may be given with
+// or instead of q. Optional parameters: strategy=index|scan, limit,
+// offset, cursor (the next_cursor of a previous response),
+// facets=true for category, brand and price aggregations,
+// fuzzy=true to correct misspelled terms, highlight=true to mark
+// matched terms in each hit, and timeout_ms to override the server's
+// default deadline. A search that runs out of time returns the hits
+// found so far with timed_out set. One whose client disconnects is
+// abandoned.
 func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "only GET is supported",
-		})
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
 		return
 	}
 
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "query parameter 'q' is required",
-		})
+	req, timeout, err := searchRequest(r.URL.Query(), h.searchTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	result := search.Execute(h.store, query)
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := h.engine.Execute(ctx, req)
+	if r.Context().Err() != nil {
+		return // the client has gone
+	}
+	if err == nil {
+		h.metrics.observeSearch(result)
+	}
+	var syntaxErr *search.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    err.Error(),
+			"position": syntaxErr.Pos,
+		})
+		return
+	case err != nil:
+		writeError(w, searchErrorStatus(err), err.Error())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
 
-// Health handles GET /health for ALB health checks.
-func (h *ProductHandler) Health(w http.ResponseWriter, r *http.Request) {
+// searchRequest reads a search from the query parameters described at
+// Search, along with the timeout to run it under; defaultTimeout
+// applies unless timeout_ms is given. Its errors are fit for a 400.
+func searchRequest(params url.Values, defaultTimeout time.Duration) (search.Request, time.Duration, error) {
+	req := search.Request{
+		Query: params.Get("q"),
+		Filters: search.Filters{
+			Category: params.Get("category"),
+			Brand:    params.Get("brand"),
+		},
+		Facets:    params.Get("facets") == "true",
+		Fuzzy:     params.Get("fuzzy") == "true",
+		Highlight: params.Get("highlight") == "true",
+	}
+	if name := params.Get("strategy"); name != "" {
+		strategy, err := search.ParseStrategy(name)
+		if err != nil {
+			return search.Request{}, 0, err
+		}
+		req.Strategy = strategy
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &req.Limit}, {"offset", &req.Offset}} {
+		if v := params.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return search.Request{}, 0, errors.New("query parameter '" + p.name + "' must be an integer")
+			}
+			*p.dst = n
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **float64
+	}{{"min_price", &req.Filters.MinPrice}, {"max_price", &req.Filters.MaxPrice}} {
+		if v := params.Get(p.name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return search.Request{}, 0, errors.New("query parameter '" + p.name + "' must be a number")
+			}
+			*p.dst = &f
+		}
+	}
+	timeout := defaultTimeout
+	if v := params.Get("timeout_ms"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 1 || ms > int(maxSearchTimeout/time.Millisecond) {
+			return search.Request{}, 0, errors.New("query parameter 'timeout_ms' must be between 1 and " + strconv.Itoa(int(maxSearchTimeout/time.Millisecond)))
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	if token := params.Get("cursor"); token != "" {
+		cursor, err := search.ParseCursor(token)
+		if err != nil {
+			return search.Request{}, 0, err
+		}
+		req.Cursor = cursor
+	}
+	return req, timeout, nil
+}
+
+// Suggest handles GET /products/suggest?prefix={prefix}[&limit={n}]
+func (h *ProductHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+
+	params := r.URL.Query()
+	prefix := params.Get("prefix")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, "query parameter 'prefix' is required")
+		return
+	}
+	limit := search.MaxSuggestions
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > search.MaxSuggestions {
+			writeError(w, http.StatusBadRequest, "query parameter 'limit' must be between 1 and "+strconv.Itoa(search.MaxSuggestions))
+			return
+		}
+		limit = n
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(map[string]any{
+		"prefix":      prefix,
+		"suggestions": h.engine.Suggest(prefix, limit),
+	})
+}
+
+// CacheStats handles GET /products/search/cache, reporting the search
+// cache's hit, miss, eviction and invalidation counts.
+func (h *ProductHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+	stats, ok := h.engine.CacheStats()
+	if !ok {
+		writeError(w, http.StatusNotFound, "search cache is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// Health handles GET /health for ALB health checks. It reports
+// readiness: while the catalog is loading the status is "loading" with
+// a progress percentage, and the code is 503 unless partial serving is
+// enabled. Once the service is draining the status is "draining" and
+// the code 503.
+func (h *ProductHandler) Health(w http.ResponseWriter, r *http.Request) {
+	body := map[string]string{
 		"status":   "healthy",
 		"products": strconv.Itoa(h.store.Count()),
+	}
+	status := http.StatusOK
+	if h.draining.Load() {
+		body["status"] = "draining"
+		writeJSON(w, http.StatusServiceUnavailable, body)
+		return
+	}
+	if h.loading != nil {
+		if loaded, total, done := h.loading.Progress(); !done {
+			body["status"] = "loading"
+			body["progress"] = strconv.FormatFloat(percent(loaded, total), 'f', 1, 64) + "%"
+			if !h.servePartial {
+				status = http.StatusServiceUnavailable
+			}
+		}
+	}
+	writeJSON(w, status, body)
+}
+
+// Live handles GET /health/live. It reports liveness: the process is
+// up and serving HTTP, whether or not the catalog is ready.
+func (h *ProductHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// percent returns loaded as a percentage of total.
+func percent(loaded, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(loaded) * 100 / float64(total)
+}
+
+// searchErrorStatus maps a search.Execute error to an HTTP status.
+func searchErrorStatus(err error) int {
+	if errors.Is(err, search.ErrInvalidRequest) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON sends v as a JSON body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a JSON error body with the given status code.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{
+		"error": msg,
 	})
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"product-search/model"
+	"product-search/search"
+	"product-search/store"
+)
+
+// testCatalog serves products with every route registered and returns
+// the mux and its store.
+func testCatalog(t *testing.T, products ...model.Product) (*http.ServeMux, *ProductHandler, store.Store) {
+	t.Helper()
+	s := store.New()
+	for _, p := range products {
+		s.Put(p)
+	}
+	h := New(s, search.New(s, search.StrategyIndex))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	return mux, h, s
+}
+
+// do sends a request with body to mux and returns the response.
+func do(mux http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, r))
+	return w
+}
+
+// TestHealthWhileDraining checks that draining fails only the
+// readiness check: liveness and requests keep being served while load
+// balancers move away.
+func TestHealthWhileDraining(t *testing.T) {
+	mux, h, _ := testCatalog(t, model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 5})
+	if w := do(mux, "GET", "/health", ""); w.Code != http.StatusOK {
+		t.Fatalf("health before draining: status %d", w.Code)
+	}
+
+	h.Drain()
+	w := do(mux, "GET", "/health", "")
+	var body map[string]string
+	json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusServiceUnavailable || body["status"] != "draining" {
+		t.Fatalf("health while draining: status %d, %v; want 503 draining", w.Code, body)
+	}
+	for _, target := range []string{"/health/live", "/products/1", "/products/search?q=widget"} {
+		if w := do(mux, "GET", target, ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s while draining: status %d", target, w.Code)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"product-search/metrics"
+	"product-search/search"
+)
+
+// countBuckets suit per-search product counts, from none to the whole
+// catalog.
+var countBuckets = []float64{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
+
+// handlerMetrics are the metrics served on /metrics. Request metrics
+// are recorded per route pattern, method (see methodLabel) and status
+// code; store and cache metrics are read at scrape time.
+type handlerMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.Counter
+	latency  *metrics.Histogram
+	checked  *metrics.Histogram
+	found    *metrics.Histogram
+	timeouts *metrics.Counter
+}
+
+// newHandlerMetrics creates a registry with the request, search and
+// runtime metrics every kind of instance serves.
+func newHandlerMetrics() *handlerMetrics {
+	r := metrics.NewRegistry()
+	m := &handlerMetrics{
+		registry: r,
+		requests: r.NewCounter("http_requests_total",
+			"HTTP requests handled, by route, method and status code.", "route", "method", "code"),
+		latency: r.NewHistogram("http_request_duration_seconds",
+			"Time taken to handle HTTP requests, by route, method and status code.",
+			metrics.DefaultBuckets, "route", "method", "code"),
+		checked: r.NewHistogram("search_products_checked",
+			"Products checked per search, by strategy.", countBuckets, "strategy"),
+		found: r.NewHistogram("search_total_found",
+			"Products matched per search, by strategy.", countBuckets, "strategy"),
+		timeouts: r.NewCounter("search_timeouts_total",
+			"Searches that ran out of time and returned partial results, by strategy.", "strategy"),
+	}
+	r.RegisterRuntime()
+	return m
+}
+
+// registerCatalog adds the store, cache and loading metrics of an
+// instance holding a catalog.
+func (m *handlerMetrics) registerCatalog(h *ProductHandler) {
+	r := m.registry
+	cache := func(value func(s search.CacheStats) float64) func() float64 {
+		return func() float64 {
+			stats, _ := h.engine.CacheStats()
+			return value(stats)
+		}
+	}
+	r.NewCounterFunc("search_cache_hits_total", "Searches answered from the cache.",
+		cache(func(s search.CacheStats) float64 { return float64(s.Hits) }))
+	r.NewCounterFunc("search_cache_misses_total", "Searches that missed the cache.",
+		cache(func(s search.CacheStats) float64 { return float64(s.Misses) }))
+	r.NewGaugeFunc("search_cache_hit_ratio", "Fraction of cache lookups that hit since startup.",
+		cache(func(s search.CacheStats) float64 {
+			if s.Hits+s.Misses == 0 {
+				return 0
+			}
+			return float64(s.Hits) / float64(s.Hits+s.Misses)
+		}))
+	r.NewCounterFunc("search_cache_evictions_total", "Cache entries evicted to make room.",
+		cache(func(s search.CacheStats) float64 { return float64(s.Evictions) }))
+	r.NewCounterFunc("search_cache_invalidations_total", "Cache entries dropped by catalog writes.",
+		cache(func(s search.CacheStats) float64 { return float64(s.Invalidations) }))
+	r.NewGaugeFunc("search_cache_entries", "Entries in the search cache.",
+		cache(func(s search.CacheStats) float64 { return float64(s.Entries) }))
+
+	r.NewGaugeFunc("product_store_products", "Products in the store.",
+		func() float64 { return float64(h.store.Count()) })
+	r.NewGaugeFunc("product_catalog_loaded_ratio", "Fraction of the initial catalog loaded; 1 once loading is done.",
+		func() float64 {
+			if h.loading == nil {
+				return 1
+			}
+			loaded, total, done := h.loading.Progress()
+			if done {
+				return 1
+			}
+			return percent(loaded, total) / 100
+		})
+}
+
+// instrument wraps next to count and time its requests under route.
+func (m *handlerMetrics) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		code, method := strconv.Itoa(rec.status), methodLabel(r.Method)
+		m.requests.Inc(route, method, code)
+		m.latency.Observe(time.Since(start).Seconds(), route, method, code)
+	}
+}
+
+// methodLabel is the method label of a request. Methods the API does
+// not serve are counted together as "other", so clients cannot create
+// series at will.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return method
+	}
+	return "other"
+}
+
+// observeSearch records the shape of a completed search.
+func (m *handlerMetrics) observeSearch(result search.Result) {
+	strategy := string(result.Strategy)
+	m.checked.Observe(float64(result.Checked), strategy)
+	m.found.Observe(float64(result.TotalFound), strategy)
+	if result.TimedOut {
+		m.timeouts.Inc(strategy)
+	}
+}
+
+// statusRecorder captures the status code a handler sends.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestMetricsLimitMethods(t *testing.T) {
+	mux, _, _ := testCatalog(t)
+	for _, method := range []string{"GET", "DELETE", "BREW", "X-ANYTHING-1", "X-ANYTHING-2"} {
+		do(mux, method, "/products/search?q=widget", "")
+	}
+	body := do(mux, "GET", "/metrics", "").Body.String()
+
+	for _, want := range []string{
+		`http_requests_total{route="/products/search",method="GET",code="200"} 1`,
+		`http_requests_total{route="/products/search",method="DELETE",code="405"} 1`,
+		`http_requests_total{route="/products/search",method="other",code="405"} 3`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(body, "BREW") || strings.Contains(body, "X-ANYTHING") {
+		t.Error("an unknown method became a label value")
+	}
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"product-search/model"
+	"product-search/shard"
+)
+
+// maxBodyBytes bounds the size of a product JSON body.
+const maxBodyBytes = 1 << 20
+
+// productPatch carries the fields of a PATCH body. Nil fields are left
+// unchanged.
+type productPatch struct {
+	ID          *int     `json:"id"`
+	Name        *string  `json:"name"`
+	Category    *string  `json:"category"`
+	Description *string  `json:"description"`
+	Brand       *string  `json:"brand"`
+	Price       *float64 `json:"price"`
+}
+
+// Products handles POST /products.
+func (h *ProductHandler) Products(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+
+	var p model.Product
+	if err := decodeBody(w, r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if p.ID < 0 {
+		writeError(w, http.StatusBadRequest, "id must be a positive integer")
+		return
+	}
+	if err := validateProduct(p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, ok, err := h.store.Insert(p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("product %d already exists", p.ID))
+		return
+	}
+	w.Header().Set("Location", "/products/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Product handles GET, PUT, PATCH and DELETE /products/{id}.
+func (h *ProductHandler) Product(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "product id must be a positive integer")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getProduct(w, id)
+	case http.MethodPut:
+		h.putProduct(w, r, id)
+	case http.MethodPatch:
+		h.patchProduct(w, r, id)
+	case http.MethodDelete:
+		h.deleteProduct(w, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "only GET, PUT, PATCH and DELETE are supported")
+	}
+}
+
+func (h *ProductHandler) getProduct(w http.ResponseWriter, id int) {
+	p, ok := h.store.Get(id)
+	if !ok {
+		writeNotFound(w, id)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// putProduct creates or fully replaces the product at id.
+func (h *ProductHandler) putProduct(w http.ResponseWriter, r *http.Request, id int) {
+	var p model.Product
+	if err := decodeBody(w, r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if p.ID != 0 && p.ID != id {
+		writeError(w, http.StatusBadRequest, "id in body does not match URL path")
+		return
+	}
+	p.ID = id
+	if err := validateProduct(p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	replaced, err := h.store.Replace(p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if replaced {
+		writeJSON(w, http.StatusOK, p)
+		return
+	}
+	_, ok, err := h.store.Insert(p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if !ok {
+		// Created by a concurrent request between the two calls.
+		writeError(w, http.StatusConflict, fmt.Sprintf("product %d was modified concurrently", id))
+		return
+	}
+	w.Header().Set("Location", "/products/"+strconv.Itoa(id))
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// patchProduct updates the fields present in the body. Concurrent
+// patches to the same product are last-writer-wins.
+func (h *ProductHandler) patchProduct(w http.ResponseWriter, r *http.Request, id int) {
+	var patch productPatch
+	if err := decodeBody(w, r, &patch); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if patch.ID != nil && *patch.ID != id {
+		writeError(w, http.StatusBadRequest, "id cannot be changed")
+		return
+	}
+
+	p, ok := h.store.Get(id)
+	if !ok {
+		writeNotFound(w, id)
+		return
+	}
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.Category != nil {
+		p.Category = *patch.Category
+	}
+	if patch.Description != nil {
+		p.Description = *patch.Description
+	}
+	if patch.Brand != nil {
+		p.Brand = *patch.Brand
+	}
+	if patch.Price != nil {
+		p.Price = *patch.Price
+	}
+	if err := validateProduct(p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	replaced, err := h.store.Replace(p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if !replaced {
+		// Deleted by a concurrent request since the Get.
+		writeNotFound(w, id)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (h *ProductHandler) deleteProduct(w http.ResponseWriter, id int) {
+	_, ok, err := h.store.Delete(id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if !ok {
+		writeNotFound(w, id)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateProduct checks the fields every stored product must satisfy.
+func validateProduct(p model.Product) error {
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return errors.New("name is required")
+	case strings.TrimSpace(p.Category) == "":
+		return errors.New("category is required")
+	case math.IsNaN(p.Price) || math.IsInf(p.Price, 0) || p.Price < 0:
+		return errors.New("price must be a non-negative number")
+	}
+	return nil
+}
+
+// decodeBody decodes a single JSON object from the request body into
+// dst, rejecting unknown fields and oversized bodies.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("invalid JSON body: %v", err)
+	}
+	if dec.More() {
+		return errors.New("invalid JSON body: unexpected data after object")
+	}
+	return nil
+}
+
+func writeNotFound(w http.ResponseWriter, id int) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("product %d not found", id))
+}
+
+// writeStoreError reports a backend failure without leaking its
+// details. A shard refusing a product another shard owns is the
+// client's mistake, reported as a 421.
+func writeStoreError(w http.ResponseWriter, err error) {
+	var notOwned *shard.NotOwnedError
+	if errors.As(err, &notOwned) {
+		writeError(w, http.StatusMisdirectedRequest, notOwned.Error())
+		return
+	}
+	log.Printf("store write failed: %v", err)
+	writeError(w, http.StatusInternalServerError, "failed to save product")
+}
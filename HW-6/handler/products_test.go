@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"product-search/model"
+	"product-search/search"
+	"product-search/shard"
+	"product-search/store"
+)
+
+// TestProductCRUD runs requests in order against one catalog, checking
+// each status and the product it leaves stored.
+func TestProductCRUD(t *testing.T) {
+	mux, _, s := testCatalog(t, model.Product{ID: 1, Name: "Widget", Category: "tools", Brand: "Acme", Price: 5})
+	steps := []struct {
+		method, target, body string
+		code                 int
+		id                   int    // product to check afterwards, if any
+		name                 string // its name, or "" if it must be gone
+	}{
+		{"GET", "/products/1", "", http.StatusOK, 1, "Widget"},
+		{"GET", "/products/2", "", http.StatusNotFound, 2, ""},
+		{"GET", "/products/abc", "", http.StatusBadRequest, 0, ""},
+		{"POST", "/products", `{"name":"Gadget","category":"toys","price":9}`, http.StatusCreated, 2, "Gadget"},
+		{"POST", "/products", `{"id":1,"name":"Clash","category":"toys","price":1}`, http.StatusConflict, 1, "Widget"},
+		{"POST", "/products", `{"name":"","category":"toys","price":1}`, http.StatusBadRequest, 0, ""},
+		{"POST", "/products", `{"name":"X","category":"toys","price":-1}`, http.StatusBadRequest, 0, ""},
+		{"POST", "/products", `{"name":"X","category":"toys","colour":"red"}`, http.StatusBadRequest, 0, ""},
+		{"PUT", "/products/1", `{"name":"Widget II","category":"tools","price":6}`, http.StatusOK, 1, "Widget II"},
+		{"PUT", "/products/7", `{"name":"Sprocket","category":"tools","price":2}`, http.StatusCreated, 7, "Sprocket"},
+		{"PUT", "/products/7", `{"id":8,"name":"Sprocket","category":"tools","price":2}`, http.StatusBadRequest, 7, "Sprocket"},
+		{"PATCH", "/products/7", `{"price":3}`, http.StatusOK, 7, "Sprocket"},
+		{"PATCH", "/products/7", `{"id":9}`, http.StatusBadRequest, 7, "Sprocket"},
+		{"PATCH", "/products/8", `{"price":3}`, http.StatusNotFound, 8, ""},
+		{"DELETE", "/products/7", "", http.StatusNoContent, 7, ""},
+		{"DELETE", "/products/7", "", http.StatusNotFound, 7, ""},
+		{"POST", "/products/1", "", http.StatusMethodNotAllowed, 1, "Widget II"},
+	}
+	for _, st := range steps {
+		w := do(mux, st.method, st.target, st.body)
+		if w.Code != st.code {
+			t.Fatalf("%s %s: %d %s, want %d", st.method, st.target, w.Code, w.Body, st.code)
+		}
+		if st.id == 0 {
+			continue
+		}
+		p, ok := s.Get(st.id)
+		if ok != (st.name != "") || p.Name != st.name {
+			t.Fatalf("after %s %s: product %d = %+v, %v; want name %q", st.method, st.target, st.id, p, ok, st.name)
+		}
+	}
+}
+
+func TestProductCreateReturnsLocation(t *testing.T) {
+	mux, _, _ := testCatalog(t, model.Product{ID: 41, Name: "Widget", Category: "tools"})
+	w := do(mux, "POST", "/products", `{"name":"Gadget","category":"toys","price":9}`)
+	var p model.Product
+	json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusCreated || p.ID != 42 || w.Header().Get("Location") != "/products/42" {
+		t.Fatalf("status %d, product %+v, location %q; want 201 for product 42", w.Code, p, w.Header().Get("Location"))
+	}
+
+	// The write reaches search at once.
+	w = do(mux, "GET", "/products/search?q=gadget", "")
+	var res search.Result
+	json.NewDecoder(w.Body).Decode(&res)
+	if res.TotalFound != 1 || res.Products[0].ID != 42 {
+		t.Fatalf("search after create = %+v", res)
+	}
+}
+
+// TestProductWriteToWrongShard checks that a shard refuses a product
+// another shard owns with 421 and leaves its catalog unchanged.
+func TestProductWriteToWrongShard(t *testing.T) {
+	part := shard.Partition{Index: 0, Count: 2}
+	foreign := 1
+	for part.Owns(foreign) {
+		foreign++
+	}
+	s := shard.NewStore(store.New(), part)
+	mux := http.NewServeMux()
+	New(s, search.New(s, search.StrategyIndex)).RegisterRoutes(mux)
+
+	id := strconv.Itoa(foreign)
+	if w := do(mux, "PUT", "/products/"+id, `{"name":"Widget","category":"tools","price":1}`); w.Code != http.StatusMisdirectedRequest {
+		t.Errorf("PUT: %d, want 421", w.Code)
+	}
+	if w := do(mux, "POST", "/products", `{"id":`+id+`,"name":"Widget","category":"tools","price":1}`); w.Code != http.StatusMisdirectedRequest {
+		t.Errorf("POST: %d, want 421", w.Code)
+	}
+	if s.Count() != 0 {
+		t.Fatalf("%d products stored", s.Count())
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"product-search/model"
+	"product-search/search"
+	"product-search/store"
+)
+
+// TestTraced sends a search with a traceparent header and checks the
+// server span continues that trace, records the response and parents
+// the search engine's spans.
+func TestTraced(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 1})
+	mux := http.NewServeMux()
+	New(s, search.New(s, search.StrategyIndex)).RegisterRoutes(mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest("GET", "/products/search?q=widget", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("search: %d", w.Code)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		spans[s.Name()] = s
+	}
+	server, ok := spans["GET /products/search"]
+	if !ok {
+		t.Fatalf("no server span among %v", spans)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("server span in trace %s, want the caller's %s", got, traceID)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span's parent is %s, want the caller's span", got)
+	}
+	attrs := make(map[string]any)
+	for _, kv := range server.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["http.route"] != "/products/search" || attrs["http.response.status_code"] != int64(200) {
+		t.Errorf("server span attributes %v", attrs)
+	}
+	execute, ok := spans["search.Execute"]
+	if !ok || execute.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("search.Execute is not a child of the server span")
+	}
+	if match, ok := spans["search.match"]; !ok || match.Parent().SpanID() != execute.SpanContext().SpanID() {
+		t.Fatalf("search.match is not a child of search.Execute")
+	}
+
+	rec.Reset()
+	failing := traced("/fail", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	if ended := rec.Ended(); len(ended) != 1 || ended[0].Status().Code != codes.Error {
+		t.Fatalf("a 500 did not mark its span as failed: %v", ended)
+	}
+}
@@ -0,0 +1,68 @@
+package limit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped,
+// so clients that have gone away take no memory.
+const sweepInterval = time.Minute
+
+// buckets is a token bucket per client. Each holds up to burst tokens
+// and gains rate a second; a request takes one.
+type buckets struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time // when tokens was counted
+}
+
+func newBuckets(rate float64, burst int) *buckets {
+	return &buckets{rate: rate, burst: float64(burst), clients: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// take takes a token from client's bucket at now. If it is empty, take
+// returns how long until it holds one instead.
+func (b *buckets) take(client string, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Sub(b.lastSweep) >= sweepInterval {
+		b.sweep(now)
+	}
+	c, ok := b.clients[client]
+	if !ok {
+		c = &bucket{tokens: b.burst, at: now}
+		b.clients[client] = c
+	}
+	c.tokens = b.refill(c, now)
+	c.at = now
+	if c.tokens < 1 {
+		return time.Duration((1 - c.tokens) / b.rate * float64(time.Second))
+	}
+	c.tokens--
+	return 0
+}
+
+// refill returns the tokens c holds at now.
+func (b *buckets) refill(c *bucket, now time.Time) float64 {
+	return min(c.tokens+now.Sub(c.at).Seconds()*b.rate, b.burst)
+}
+
+// sweep drops the buckets that are full again, which are the same as
+// new ones.
+func (b *buckets) sweep(now time.Time) {
+	for client, c := range b.clients {
+		if b.refill(c, now) >= b.burst {
+			delete(b.clients, client)
+		}
+	}
+	b.lastSweep = now
+}
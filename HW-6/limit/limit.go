@@ -0,0 +1,260 @@
+// Package limit protects the service from clients sending more than
+// it can serve.
+//
+// Design decision hidden: How request rates and concurrency are
+// bounded and how clients are told. Each route may limit the rate of
+// each client with a token bucket, answering 429 with Retry-After, and
+// may shed load by answering 503 once too many of its requests are in
+// flight. Clients are told apart by the caller package auth
+// identified, or else by IP address. Transports only name the route a
+// request is for: HTTP wraps its handlers, gRPC maps its methods to the
+// same routes and shares their budgets.
+package limit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"product-search/auth"
+)
+
+// shedRetryAfter is the Retry-After sent with a shed request. Load
+// changes quickly, so clients are asked to come back soon.
+const shedRetryAfter = time.Second
+
+// Policy is the limits on one route. Zero fields are unlimited.
+type Policy struct {
+	// Rate is how many requests per second each client may send on
+	// average, and Burst how many it may send at once.
+	Rate  float64
+	Burst int
+
+	// MaxInFlight is how many of the route's requests may be served at
+	// once, over all clients.
+	MaxInFlight int
+}
+
+// Config is the limits on each route, by route pattern.
+type Config struct {
+	Routes map[string]Policy
+
+	// TrustProxy makes clients without credentials be told apart by
+	// the address a load balancer appended to X-Forwarded-For rather
+	// than by the connection's. Set it only behind one, or clients
+	// could pick their own addresses.
+	TrustProxy bool
+}
+
+// ConfigFromEnv reads the limits:
+//
+//	RATE_LIMITS             comma-separated route=rate[/burst] entries,
+//	                        e.g. /products/search=20/40; burst defaults
+//	                        to the rate
+//	MAX_IN_FLIGHT           comma-separated route=count entries, e.g.
+//	                        /products/search=64
+//	RATE_LIMIT_TRUST_PROXY  "true" behind a load balancer
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Routes: make(map[string]Policy), TrustProxy: os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true"}
+	err := parseEntries("RATE_LIMITS", func(route, value string) error {
+		rate, burst, hasBurst := strings.Cut(value, "/")
+		p := cfg.Routes[route]
+		var err error
+		if p.Rate, err = strconv.ParseFloat(rate, 64); err != nil || p.Rate <= 0 {
+			return fmt.Errorf("rate must be a positive number, got %q", rate)
+		}
+		p.Burst = max(int(p.Rate), 1)
+		if hasBurst {
+			if p.Burst, err = strconv.Atoi(burst); err != nil || p.Burst < 1 {
+				return fmt.Errorf("burst must be a positive integer, got %q", burst)
+			}
+		}
+		cfg.Routes[route] = p
+		return nil
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	err = parseEntries("MAX_IN_FLIGHT", func(route, value string) error {
+		p := cfg.Routes[route]
+		var err error
+		if p.MaxInFlight, err = strconv.Atoi(value); err != nil || p.MaxInFlight < 1 {
+			return fmt.Errorf("count must be a positive integer, got %q", value)
+		}
+		cfg.Routes[route] = p
+		return nil
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// parseEntries calls parse with each route=value entry of environment
+// variable name.
+func parseEntries(name string, parse func(route, value string) error) error {
+	for entry := range strings.SplitSeq(os.Getenv(name), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(route, "/") {
+			return fmt.Errorf("limit: %s: want route=value, got %q", name, entry)
+		}
+		if err := parse(strings.TrimSpace(route), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("limit: %s: %s: %w", name, route, err)
+		}
+	}
+	return nil
+}
+
+// Limits enforces a Config. Each route's rate and in-flight counts are
+// shared by every transport serving it, so a gRPC search and an HTTP
+// one draw on the same budget. A nil Limits limits nothing. Safe for
+// concurrent use.
+type Limits struct {
+	cfg    Config
+	routes map[string]*routeLimits
+}
+
+// routeLimits is the state of one route's Policy.
+type routeLimits struct {
+	buckets     *buckets // nil without a rate
+	maxInFlight int64    // zero for no bound
+	inFlight    atomic.Int64
+}
+
+// New creates Limits enforcing cfg, or returns nil if cfg limits no
+// route.
+func New(cfg Config) *Limits {
+	if len(cfg.Routes) == 0 {
+		return nil
+	}
+	l := &Limits{cfg: cfg, routes: make(map[string]*routeLimits, len(cfg.Routes))}
+	for route, p := range cfg.Routes {
+		rl := &routeLimits{maxInFlight: int64(p.MaxInFlight)}
+		if p.Rate > 0 {
+			rl.buckets = newBuckets(p.Rate, p.Burst)
+		}
+		l.routes[route] = rl
+	}
+	return l
+}
+
+// Rejection is a request turned away by Admit. Overloaded tells a
+// request shed because too many were in flight from one over its
+// client's rate.
+type Rejection struct {
+	Overloaded bool
+	RetryAfter time.Duration
+}
+
+func (r *Rejection) Error() string {
+	if r.Overloaded {
+		return "server overloaded"
+	}
+	return "rate limit exceeded"
+}
+
+// Admit checks a request on route from client, as told apart by Client,
+// against the route's limits. If it is admitted, Admit returns a
+// function to call once the request has been served; otherwise it
+// returns a *Rejection. Rate-limited requests do not count as in
+// flight. On a nil Limits or a route without limits, every request is
+// admitted.
+func (l *Limits) Admit(route, client string) (done func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	rl, ok := l.routes[route]
+	if !ok {
+		return func() {}, nil
+	}
+	if rl.buckets != nil {
+		if wait := rl.buckets.take(client, time.Now()); wait > 0 {
+			return nil, &Rejection{RetryAfter: wait}
+		}
+	}
+	if rl.maxInFlight == 0 {
+		return func() {}, nil
+	}
+	if rl.inFlight.Add(1) > rl.maxInFlight {
+		rl.inFlight.Add(-1)
+		return nil, &Rejection{Overloaded: true, RetryAfter: shedRetryAfter}
+	}
+	return func() { rl.inFlight.Add(-1) }, nil
+}
+
+// Wrap wraps next, the handler of route, in the route's limits. A
+// client over its rate gets a 429, and a request arriving while
+// MaxInFlight are being served a 503, both with a Retry-After header.
+// Callers are told apart by the principal in the request context, so
+// Wrap goes inside auth's Require. On a nil Limits or a route without
+// limits, Wrap returns next unchanged.
+func (l *Limits) Wrap(route string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	if _, ok := l.routes[route]; !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done, err := l.Admit(route, l.client(r))
+		var rej *Rejection
+		if errors.As(err, &rej) {
+			status := http.StatusTooManyRequests
+			if rej.Overloaded {
+				status = http.StatusServiceUnavailable
+			}
+			reject(w, status, rej.RetryAfter, rej.Error())
+			return
+		}
+		defer done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Client identifies the sender of a request with context ctx from
+// address addr, a host and port, for Admit: by the principal auth
+// recorded in ctx, or else by IP address.
+func Client(ctx context.Context, addr string) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.Method + ":" + p.Name
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return "ip:" + host
+}
+
+// client identifies the sender of r, trusting X-Forwarded-For if
+// configured to.
+func (l *Limits) client(r *http.Request) string {
+	if _, ok := auth.FromContext(r.Context()); !ok && l.cfg.TrustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return "ip:" + ip
+			}
+		}
+	}
+	return Client(r.Context(), r.RemoteAddr)
+}
+
+// reject sends a JSON error like the handler package's, asking the
+// client to retry after wait, rounded up to whole seconds.
+func reject(w http.ResponseWriter, status int, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,158 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"product-search/auth"
+)
+
+func TestBucketRefills(t *testing.T) {
+	b := newBuckets(2, 3) // 2 a second, bursts of 3
+	now := time.Now()
+	for i := range 3 {
+		if wait := b.take("c", now); wait != 0 {
+			t.Fatalf("request %d of the burst waited %v", i+1, wait)
+		}
+	}
+	if wait := b.take("c", now); wait != 500*time.Millisecond {
+		t.Fatalf("over the burst: wait %v, want 500ms", wait)
+	}
+	if wait := b.take("other", now); wait != 0 {
+		t.Fatalf("another client waited %v", wait)
+	}
+	if wait := b.take("c", now.Add(500*time.Millisecond)); wait != 0 {
+		t.Fatalf("after refilling a token: wait %v", wait)
+	}
+	b.take("idle", now)
+	b.sweep(now.Add(time.Hour))
+	if _, ok := b.clients["idle"]; ok {
+		t.Fatal("a refilled bucket was kept by the sweep")
+	}
+}
+
+func TestAdmitRejections(t *testing.T) {
+	l := New(Config{Routes: map[string]Policy{
+		"/rate": {Rate: 1, Burst: 1},
+		"/busy": {MaxInFlight: 1},
+	}})
+	if _, err := l.Admit("/rate", "a"); err != nil {
+		t.Fatal(err)
+	}
+	var rej *Rejection
+	if _, err := l.Admit("/rate", "a"); !errors.As(err, &rej) || rej.Overloaded || rej.RetryAfter <= 0 {
+		t.Fatalf("second request within a second: %v", err)
+	}
+
+	done, err := l.Admit("/busy", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Admit("/busy", "b"); !errors.As(err, &rej) || !rej.Overloaded {
+		t.Fatalf("request while the route is busy: %v, want it shed", err)
+	}
+	done()
+	if _, err := l.Admit("/busy", "b"); err != nil {
+		t.Fatalf("request after the busy one finished: %v", err)
+	}
+
+	if _, err := l.Admit("/other", "a"); err != nil {
+		t.Fatalf("unlimited route: %v", err)
+	}
+	var none *Limits
+	if _, err := none.Admit("/rate", "a"); err != nil {
+		t.Fatalf("nil Limits: %v", err)
+	}
+}
+
+// TestAdmitBoundsInFlight admits requests from many goroutines and
+// checks no more than MaxInFlight are ever served at once.
+func TestAdmitBoundsInFlight(t *testing.T) {
+	const most = 4
+	l := New(Config{Routes: map[string]Policy{"/r": {MaxInFlight: most}}})
+	var serving, peak, shed atomic.Int64
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Go(func() {
+			for range 200 {
+				done, err := l.Admit("/r", "c")
+				if err != nil {
+					shed.Add(1)
+					continue
+				}
+				n := serving.Add(1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(10 * time.Microsecond)
+				serving.Add(-1)
+				done()
+			}
+		})
+	}
+	wg.Wait()
+	if peak.Load() > most {
+		t.Fatalf("%d requests served at once, limit %d", peak.Load(), most)
+	}
+	if shed.Load() == 0 {
+		t.Log("no request was shed; the limit was never reached")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	l := New(Config{Routes: map[string]Policy{"/slow": {MaxInFlight: 1}, "/fast": {Rate: 1, Burst: 1}}})
+
+	go l.Wrap("/slow", slow).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	<-started
+	rec := httptest.NewRecorder()
+	l.Wrap("/slow", slow).ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	close(release)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("request over MaxInFlight: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	fast := l.Wrap("/fast", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	codes := make([]int, 2)
+	for i := range codes {
+		rec := httptest.NewRecorder()
+		fast.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+		codes[i] = rec.Code
+		if i == 1 && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("Retry-After %q, want 1", rec.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("codes %v, want 200 then 429", codes)
+	}
+}
+
+func TestClientKeys(t *testing.T) {
+	l := New(Config{Routes: map[string]Policy{"/r": {Rate: 1}}, TrustProxy: true})
+	r := httptest.NewRequest("GET", "/r", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	if got := l.client(r); got != "ip:10.0.0.1" {
+		t.Errorf("client = %q", got)
+	}
+	r.Header.Add("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+	if got := l.client(r); got != "ip:2.2.2.2" {
+		t.Errorf("behind a proxy, client = %q, want the last hop", got)
+	}
+	r = r.WithContext(auth.NewContext(r.Context(), &auth.Principal{Method: "key", Name: "ci"}))
+	if got := l.client(r); got != "key:ci" {
+		t.Errorf("authenticated client = %q", got)
+	}
+	if got := Client(context.Background(), "[::1]:80"); got != "ip:::1" {
+		t.Errorf("Client = %q", got)
+	}
+}
@@ -5,44 +5,369 @@
 // design decision behind a stable interface:
 //
 //	model      → product data representation
-//	store      → storage mechanism (sync.Map, concurrency)
+//	store      → storage mechanism (in-memory or on-disk log, concurrency)
 //	seeddata   → seed catalog source and content
-//	generator  → expansion strategy (seeds → 100K products)
-//	search     → algorithm, iteration bounds, matching logic
+//	generator  → expansion strategy (seeds → synthetic catalog)
+//	search     → algorithm, indexing, iteration bounds, matching logic
 //	handler    → HTTP transport, routing, serialization
+//	pb         → gRPC API definition and generated stubs
+//	grpcserver → gRPC transport, mapping the API onto search and store
+//	shard      → catalog partitioning and scatter-gather search
+//	metrics    → metric representation and exposition
+//	auth       → caller credentials and permissions
+//	limit      → rate limiting and load shedding
+//	tracing    → trace export and sampling
 //
 // main is the composition root: it wires modules together but
 // contains no domain logic itself.
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+
+	"product-search/auth"
 	"product-search/generator"
+	"product-search/grpcserver"
 	"product-search/handler"
+	"product-search/limit"
+	"product-search/search"
+	"product-search/shard"
 	"product-search/store"
+	"product-search/tracing"
 )
 
 func main() {
-	// 1. Create the storage layer.
-	productStore := store.New()
+	// 1. Read the instance's role. SHARD_ROLE=shard holds one
+	//    partition of the catalog, and SHARD_ROLE=coordinator holds
+	//    none but searches all the shards (see runCoordinator).
+	//    Unset, the instance holds the whole catalog.
+	shardConfig, err := shard.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//    AUTH_* select the credentials callers must present (see
+	//    auth.ConfigFromEnv); with none set the API is open.
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authenticator, err := auth.New(authConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if authenticator == nil {
+		log.Println("Authentication is off: no AUTH_* credentials configured")
+	}
+
+	//    RATE_LIMITS and MAX_IN_FLIGHT bound how fast each client may
+	//    call a route and how many of its requests are served at once
+	//    (see limit.ConfigFromEnv), over HTTP and the matching gRPC
+	//    methods together; by default nothing is limited.
+	limitConfig, err := limit.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	limits := limit.New(limitConfig)
+
+	//    OTEL_TRACES_EXPORTER sends traces of requests through the
+	//    handlers, searches and store to an OTLP collector or a file
+	//    (see tracing.ConfigFromEnv); by default none are exported.
+	tracingConfig, err := tracing.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	stopTracing, err := tracing.Start(context.Background(), tracingConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if shardConfig.Role == shard.RoleCoordinator {
+		runCoordinator(shardConfig, authenticator, limits)
+		flushTraces(stopTracing)
+		log.Println("Shutdown complete")
+		return
+	}
+
+	// 2. Create the storage layer. By default the catalog lives in
+	//    memory; STORE_PATH selects the on-disk log instead, which
+	//    keeps the catalog across restarts.
+	var productStore store.Store = store.New()
+	var diskStore *store.DiskStore
+	if path := os.Getenv("STORE_PATH"); path != "" {
+		if diskStore, err = store.Open(path); err != nil {
+			log.Fatal(err)
+		}
+		productStore = diskStore
+	}
+	//    A shard only accepts products of its own partition, and
+	//    gives new products IDs from it.
+	if shardConfig.Role == shard.RoleShard {
+		productStore = shard.NewStore(productStore, shardConfig.Partition)
+	}
+
+	// 3. Create the search engine before populating so its index is
+	//    built incrementally as products are Put. SEARCH_STRATEGY picks
+	//    the default strategy ("index" or "scan").
+	strategy := search.StrategyIndex
+	if name := os.Getenv("SEARCH_STRATEGY"); name != "" {
+		if strategy, err = search.ParseStrategy(name); err != nil {
+			log.Fatal(err)
+		}
+	}
+	engine := search.New(productStore, strategy)
+
+	//    Repeated searches are served from a cache of
+	//    SEARCH_CACHE_SIZE entries (0 turns it off) that expire after
+	//    SEARCH_CACHE_TTL; catalog writes invalidate affected entries.
+	if cacheSize := envInt("SEARCH_CACHE_SIZE", 1000, 0); cacheSize > 0 {
+		engine.UseCache(search.NewCache(cacheSize, envDuration("SEARCH_CACHE_TTL", time.Minute, time.Nanosecond)))
+	}
+
+	// 4. Wire the HTTP handlers and the gRPC service to the store and
+	//    search engine. Searches get SEARCH_TIMEOUT to run unless they
+	//    ask for their own.
+	searchTimeout := envDuration("SEARCH_TIMEOUT", 2*time.Second, 0)
+	h := handler.New(productStore, engine)
+	h.SetSearchTimeout(searchTimeout)
+	h.UseAuth(authenticator)
+	h.UseLimits(limits)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	rpc := grpcserver.New(productStore, engine)
+	rpc.SetSearchTimeout(searchTimeout)
+	grpcOptions := append(grpcserver.AuthOptions(authenticator), grpcserver.LimitOptions(limits)...)
+	grpcOptions = append(grpcOptions, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	grpcServer := grpc.NewServer(grpcOptions...)
+	rpc.Register(grpcServer)
+
+	// 5. Populate with generated products in the background, unless a
+	//    persistent store already holds a complete catalog from a
+	//    previous run. A population interrupted on a persistent store
+	//    resumes, generating only the products it had not stored yet.
+	//    GEN_PROFILE and the GEN_* variables shape the generated
+	//    catalog, of which a shard keeps only its partition. /health
+	//    reports progress meanwhile; with SERVE_PARTIAL=true the
+	//    product endpoints serve the partial catalog instead of
+	//    answering 503. A shutdown stops population early.
+	populateCtx, stopPopulating := context.WithCancel(context.Background())
+	populated := make(chan struct{})
+	reuse := false
+	if diskStore != nil {
+		var size int
+		if size, reuse = diskStore.Populated(); reuse {
+			log.Printf("Reusing persisted catalog: %d products (%d generated)\n", productStore.Count(), size)
+		} else if n := productStore.Count(); n > 0 {
+			log.Printf("Resuming interrupted population: %d products already stored\n", n)
+		}
+	}
+	if !reuse {
+		genConfig, err := generator.ConfigFromEnv()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if shardConfig.Role == shard.RoleShard {
+			genConfig.Owns = shardConfig.Partition.Owns
+			log.Printf("Shard %d of %d\n", shardConfig.Partition.Index, shardConfig.Partition.Count)
+		}
+		population := generator.Start(populateCtx, productStore, genConfig)
+		servePartial := os.Getenv("SERVE_PARTIAL") == "true"
+		h.TrackLoading(population, servePartial)
+		rpc.TrackLoading(population, servePartial)
+		go func() {
+			defer close(populated)
+			population.Wait()
+			if diskStore != nil && populateCtx.Err() == nil {
+				if err := diskStore.MarkPopulated(genConfig.Size); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}()
+	} else {
+		close(populated)
+	}
+
+	// 6. Serve HTTP on PORT and gRPC on GRPC_PORT until told to stop.
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		log.Printf("Product Search gRPC service listening on :%s\n", grpcPort)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	serve(mux, grpcServer, func() {
+		h.Drain()
+		rpc.Drain()
+	})
 
-	// 2. Populate with generated products.
-	generator.Populate(productStore)
+	// 7. Stop population, close the store, flushing the log, and
+	//    export the last spans.
+	stopPopulating()
+	<-populated
+	if diskStore != nil {
+		if err := diskStore.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	flushTraces(stopTracing)
+	log.Println("Shutdown complete")
+}
 
-	// 3. Wire HTTP handlers to the store.
-	h := handler.New(productStore)
+// runCoordinator serves a sharding coordinator: searches are fanned
+// out to the shards at SHARD_URLS and merged, under SEARCH_TIMEOUT
+// unless they ask for their own, and product reads and writes are
+// forwarded to the owning shard. It serves HTTP only. Searches reach
+// the shards with SHARD_API_KEY, and with SEARCH_TIMEOUT at zero
+// still give up on them after SHARD_TIMEOUT.
+func runCoordinator(cfg shard.Config, a *auth.Authenticator, l *limit.Limits) {
+	h := handler.NewCoordinator(shard.NewCoordinator(cfg))
+	h.SetSearchTimeout(envDuration("SEARCH_TIMEOUT", 2*time.Second, 0))
+	h.UseAuth(a)
+	h.UseLimits(l)
 	mux := http.NewServeMux()
 	h.RegisterRoutes(mux)
+	log.Printf("Coordinating %d shards\n", len(cfg.Shards))
+	serve(mux, nil, h.Drain)
+}
 
-	// 4. Start serving. Port is read from the PORT env var so that
-	//    Docker / cloud orchestrators can inject it at runtime.
+// serve serves mux over HTTP until SIGTERM or SIGINT, then shuts it
+// and grpcServer, if not nil, down gracefully.
+//
+// The port is read from the PORT env var so that Docker / cloud
+// orchestrators can inject it at runtime. The HTTP_* variables bound
+// how long a client may take to send a request, to read a response
+// and to idle between requests; bulk import and export lift the
+// deadlines for their streams.
+//
+// SIGTERM is how ECS stops a task. drain is called at once, turning
+// the health checks unhealthy so traffic moves elsewhere, and requests
+// keep being served for SHUTDOWN_DRAIN while it does. Then the
+// listeners close and in-flight requests get SHUTDOWN_TIMEOUT to
+// finish before their connections are cut. The two together must fit
+// within the orchestrator's stop timeout (30s on ECS by default). A
+// second signal exits at once.
+func serve(mux http.Handler, grpcServer *grpc.Server, drain func()) {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	log.Printf("Product Search Service listening on :%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, mux))
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second, 0),
+		ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 30*time.Second, 0),
+		WriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 90*time.Second, 0),
+		IdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute, 0),
+		MaxHeaderBytes:    envInt("HTTP_MAX_HEADER_BYTES", 64<<10, 1),
+	}
+	drainFor := envDuration("SHUTDOWN_DRAIN", 15*time.Second, 0)
+	shutdownTimeout := envDuration("SHUTDOWN_TIMEOUT", 10*time.Second, 0)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Product Search Service listening on :%s\n", port)
+		serveErr <- server.ListenAndServe()
+	}()
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-signals.Done():
+	}
+	stopSignals()
+
+	shutdown(server, grpcServer, drain, drainFor, shutdownTimeout)
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		log.Println(err)
+	}
+}
+
+// shutdown calls drain, keeps serving for drainFor, then stops server
+// and grpcServer, if not nil, giving in-flight requests up to timeout
+// before cutting them off.
+func shutdown(server *http.Server, grpcServer *grpc.Server, drain func(),
+	drainFor, timeout time.Duration) {
+	log.Printf("Shutting down: draining for %s\n", drainFor)
+	drain()
+	server.SetKeepAlivesEnabled(false)
+	time.Sleep(drainFor)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	grpcStopped := make(chan struct{})
+	if grpcServer != nil {
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown timed out, closing remaining HTTP connections: %v\n", err)
+		server.Close()
+	}
+	if grpcServer != nil {
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			log.Println("Shutdown timed out, cancelling remaining RPCs")
+			grpcServer.Stop()
+		}
+	}
+}
+
+// flushTraces exports the spans still buffered by tracing, waiting at
+// most SHUTDOWN_TIMEOUT.
+func flushTraces(stop func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 10*time.Second, 0))
+	defer cancel()
+	if err := stop(ctx); err != nil {
+		log.Printf("Flushing traces: %v\n", err)
+	}
+}
+
+// envDuration returns the duration in environment variable name, or
+// def if it is unset. It exits if the value is malformed or below least.
+func envDuration(name string, def, least time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < least {
+		log.Fatalf("Invalid %s %q", name, v)
+	}
+	return d
+}
+
+// envInt returns the integer in environment variable name, or def if
+// it is unset. It exits if the value is malformed or below least.
+func envInt(name string, def, least int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < least {
+		log.Fatalf("Invalid %s %q", name, v)
+	}
+	return n
 }
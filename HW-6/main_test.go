@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"product-search/handler"
+	"product-search/model"
+	"product-search/search"
+	"product-search/store"
+)
+
+// shutdownServer serves the catalog routes plus /slow, which holds each
+// request until release is closed, on a local port. It returns the
+// server, its base URL, the product handler and a channel receiving
+// each /slow request as it starts.
+func shutdownServer(t *testing.T, release <-chan struct{}) (
+	*http.Server, string, *handler.ProductHandler, <-chan struct{}) {
+	t.Helper()
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Widget", Category: "tools", Price: 5})
+	h := handler.New(s, search.New(s, search.StrategyIndex))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	started := make(chan struct{}, 1)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: mux}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return server, "http://" + ln.Addr().String(), h, started
+}
+
+// client opens a connection per request, as load balancer health
+// checks and fresh clients do.
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// getSlow starts a request to /slow and returns a channel receiving its
+// outcome.
+func getSlow(url string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		done <- err
+	}()
+	return done
+}
+
+// TestShutdownDrainsFirst checks the shutdown order: health turns
+// unhealthy at once while requests are still accepted, then the
+// listener closes and in-flight requests are let finish.
+func TestShutdownDrainsFirst(t *testing.T) {
+	release := make(chan struct{})
+	server, url, h, started := shutdownServer(t, release)
+	slow := getSlow(url)
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		shutdown(server, nil, h.Drain, 300*time.Millisecond, 10*time.Second)
+		close(stopped)
+	}()
+
+	// Drain is called before shutdown sleeps, so health is failing
+	// well within the drain period.
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for {
+		resp, err := client.Get(url + "/health")
+		if err != nil {
+			t.Fatalf("health while draining: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health still %d while draining", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := client.Get(url + "/products/1")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request while draining: %v, %v", resp, err)
+	}
+	resp.Body.Close()
+
+	// After the drain period new connections are refused, but the
+	// in-flight request holds shutdown open until it finishes.
+	for {
+		resp, err := client.Get(url + "/health/live")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("shutdown returned with a request in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	<-stopped
+}
+
+// TestShutdownCutsOffAfterTimeout checks that a request still running
+// when the shutdown timeout passes does not hold shutdown up.
+func TestShutdownCutsOffAfterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, url, h, started := shutdownServer(t, release)
+	slow := getSlow(url)
+	<-started
+
+	start := time.Now()
+	shutdown(server, nil, h.Drain, 0, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("shutdown took %s", elapsed)
+	}
+	if err := <-slow; err == nil {
+		t.Fatal("the cut-off request succeeded")
+	}
+}
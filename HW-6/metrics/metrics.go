@@ -0,0 +1,261 @@
+// Package metrics collects service metrics and exposes them in the
+// Prometheus text format.
+//
+// Design decision hidden: How metrics are represented, aggregated and
+// exported. Modules record into counters and histograms, or register
+// functions read at scrape time, without knowing the exposition
+// format. The standard library is enough for the text format, so no
+// client library is pulled in; swapping one in (or moving to OTLP)
+// would only touch this package.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metric families in registration order. Safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+// family is one named metric with its samples.
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// WriteText writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// Counter is a monotonically increasing count, split by label values.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*float64
+}
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, series: make(map[string]*float64)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series with the given
+// label values.
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = new(float64)
+		c.series[key] = s
+	}
+	*s += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.series) {
+		c.sample(w, "", key, "", *c.series[key])
+	}
+}
+
+// Histogram counts observations into cumulative buckets, split by
+// label values.
+type Histogram struct {
+	desc
+	buckets []float64 // upper bounds, ascending, without +Inf
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper
+// bounds and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: slices.Sorted(slices.Values(buckets)),
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	i, _ := slices.BinarySearch(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var total uint64
+		for i, n := range s.counts {
+			total += n
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			h.sample(w, "_bucket", key, `le="`+formatFloat(le)+`"`, float64(total))
+		}
+		h.sample(w, "_sum", key, "", s.sum)
+		h.sample(w, "_count", key, "", float64(total))
+	}
+}
+
+// funcMetric is a single unlabelled value read at scrape time.
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is fn's result at scrape
+// time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc{name, help, "gauge", nil}, fn})
+}
+
+// NewCounterFunc registers a counter whose value is fn's result at
+// scrape time, for counts kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc{name, help, "counter", nil}, fn})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.header(w)
+	f.sample(w, "", "", "", f.fn())
+}
+
+// desc is what every family shares: its name, help, type and label
+// names.
+type desc struct {
+	name, help, typ string
+	labels          []string
+}
+
+// key encodes label values as the rendered label pairs, so series sort
+// and print without further work.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	pairs := make([]string, len(values))
+	for i, v := range values {
+		pairs[i] = d.labels[i] + `="` + escapeLabel(v) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, strings.ReplaceAll(d.help, "\n", " "), d.name, d.typ)
+}
+
+// sample writes one line. key holds the series' label pairs and extra
+// any more, such as a bucket's le.
+func (d *desc) sample(w *bufio.Writer, suffix, key, extra string, v float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+	if labels := joinNonEmpty(key, extra); labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func joinNonEmpty(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "," + b
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests served,\nby route.", "route", "code")
+	latency := r.NewHistogram("latency_seconds", "Request latency.", []float64{1, 0.1}, "route")
+	r.NewGaugeFunc("items", "Items stored.", func() float64 { return 42 })
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "200")
+	requests.Inc(`say "hi"\now`+"\n", "500")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a") // a bound is inclusive
+	latency.Observe(3, "/a")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP requests_total Requests served, by route.
+# TYPE requests_total counter
+requests_total{route="/a",code="200"} 2
+requests_total{route="/b",code="200"} 1
+requests_total{route="say \"hi\"\\now\n",code="500"} 1
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 3.15
+latency_seconds_count{route="/a"} 3
+# HELP items Items stored.
+# TYPE items gauge
+items 42
+`
+	if got := b.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterFunc("restarts_total", "Restarts.", func() float64 { return 1 })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if want := "# TYPE restarts_total counter\nrestarts_total 1\n"; !strings.HasSuffix(w.Body.String(), want) {
+		t.Errorf("body %q, want it to end %q", w.Body.String(), want)
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	c := NewRegistry().NewCounter("requests_total", "Requests.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with too many label values did not panic")
+		}
+	}()
+	c.Inc("/a", "extra")
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// memStatsMaxAge bounds how stale the memory statistics reported in one
+// scrape may be; ReadMemStats stops the world, so it is read once per
+// scrape rather than once per metric.
+const memStatsMaxAge = time.Second
+
+// RegisterRuntime registers Go runtime and process metrics: goroutines,
+// heap and GC statistics, and the process start time.
+func (r *Registry) RegisterRuntime() {
+	var (
+		mu   sync.Mutex
+		ms   runtime.MemStats
+		read time.Time
+	)
+	mem := func(field func(*runtime.MemStats) float64) func() float64 {
+		return func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			if time.Since(read) > memStatsMaxAge {
+				runtime.ReadMemStats(&ms)
+				read = time.Now()
+			}
+			return field(&ms)
+		}
+	}
+
+	start := float64(time.Now().UnixNano()) / 1e9
+	r.NewGaugeFunc("process_start_time_seconds", "Start time of the process since the Unix epoch in seconds.",
+		func() float64 { return start })
+	r.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+	r.NewGaugeFunc("go_sched_gomaxprocs_threads", "Current GOMAXPROCS setting: OS threads that may run Go code simultaneously.",
+		func() float64 { return float64(runtime.GOMAXPROCS(0)) })
+	r.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.HeapAlloc) }))
+	r.NewGaugeFunc("go_memstats_heap_inuse_bytes", "Bytes in in-use heap spans.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.HeapInuse) }))
+	r.NewGaugeFunc("go_memstats_heap_objects", "Number of allocated heap objects.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.HeapObjects) }))
+	r.NewGaugeFunc("go_memstats_sys_bytes", "Bytes of memory obtained from the OS.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.Sys) }))
+	r.NewCounterFunc("go_memstats_alloc_bytes_total", "Total bytes allocated for heap objects.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.TotalAlloc) }))
+	r.NewCounterFunc("go_gc_cycles_total", "Number of completed GC cycles.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.NumGC) }))
+	r.NewCounterFunc("go_gc_pause_seconds_total", "Total time the world was stopped for GC, in seconds.",
+		mem(func(ms *runtime.MemStats) float64 { return float64(ms.PauseTotalNs) / 1e9 }))
+}
@@ -0,0 +1,7 @@
+// Package pb holds the protobuf messages and gRPC client and server
+// stubs of the product search API, generated from
+// product_search.proto. Edit the .proto and run go generate; do not
+// edit the generated files.
+package pb
+
+//go:generate protoc --proto_path=.. --go_out=.. --go_opt=module=product-search --go-grpc_out=.. --go-grpc_opt=module=product-search pb/product_search.proto
@@ -0,0 +1,1014 @@
+// The product search gRPC API. It mirrors the HTTP/JSON endpoints
+// served by package handler.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: pb/product_search.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Strategy int32
+
+const (
+	// The server's default strategy.
+	Strategy_STRATEGY_UNSPECIFIED Strategy = 0
+	// Answer from the inverted index.
+	Strategy_STRATEGY_INDEX Strategy = 1
+	// Check a bounded window of products one by one.
+	Strategy_STRATEGY_SCAN Strategy = 2
+)
+
+// Enum value maps for Strategy.
+var (
+	Strategy_name = map[int32]string{
+		0: "STRATEGY_UNSPECIFIED",
+		1: "STRATEGY_INDEX",
+		2: "STRATEGY_SCAN",
+	}
+	Strategy_value = map[string]int32{
+		"STRATEGY_UNSPECIFIED": 0,
+		"STRATEGY_INDEX":       1,
+		"STRATEGY_SCAN":        2,
+	}
+)
+
+func (x Strategy) Enum() *Strategy {
+	p := new(Strategy)
+	*p = x
+	return p
+}
+
+func (x Strategy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Strategy) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_product_search_proto_enumTypes[0].Descriptor()
+}
+
+func (Strategy) Type() protoreflect.EnumType {
+	return &file_pb_product_search_proto_enumTypes[0]
+}
+
+func (x Strategy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Strategy.Descriptor instead.
+func (Strategy) EnumDescriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{0}
+}
+
+type Product struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Category      string                 `protobuf:"bytes,3,opt,name=category,proto3" json:"category,omitempty"`
+	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Brand         string                 `protobuf:"bytes,5,opt,name=brand,proto3" json:"brand,omitempty"`
+	Price         float64                `protobuf:"fixed64,6,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Product) Reset() {
+	*x = Product{}
+	mi := &file_pb_product_search_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Product) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Product) ProtoMessage() {}
+
+func (x *Product) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Product.ProtoReflect.Descriptor instead.
+func (*Product) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Product) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Product) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Product) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Product) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Product) GetBrand() string {
+	if x != nil {
+		return x.Brand
+	}
+	return ""
+}
+
+func (x *Product) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+type SearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Query in the search query language, e.g.
+	// brand:apple AND (laptop OR tablet) -refurbished price:<500.
+	Query    string   `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Category string   `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	Brand    string   `protobuf:"bytes,3,opt,name=brand,proto3" json:"brand,omitempty"`
+	MinPrice *float64 `protobuf:"fixed64,4,opt,name=min_price,json=minPrice,proto3,oneof" json:"min_price,omitempty"`
+	MaxPrice *float64 `protobuf:"fixed64,5,opt,name=max_price,json=maxPrice,proto3,oneof" json:"max_price,omitempty"`
+	Strategy Strategy `protobuf:"varint,6,opt,name=strategy,proto3,enum=productsearch.v1.Strategy" json:"strategy,omitempty"`
+	// Zero selects the server's maximum page size.
+	Limit  int32 `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset int32 `protobuf:"varint,8,opt,name=offset,proto3" json:"offset,omitempty"`
+	// next_cursor of a previous response; excludes offset.
+	Cursor        string `protobuf:"bytes,9,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	Facets        bool   `protobuf:"varint,10,opt,name=facets,proto3" json:"facets,omitempty"`
+	Fuzzy         bool   `protobuf:"varint,11,opt,name=fuzzy,proto3" json:"fuzzy,omitempty"`
+	Highlight     bool   `protobuf:"varint,12,opt,name=highlight,proto3" json:"highlight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_pb_product_search_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetBrand() string {
+	if x != nil {
+		return x.Brand
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetMinPrice() float64 {
+	if x != nil && x.MinPrice != nil {
+		return *x.MinPrice
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMaxPrice() float64 {
+	if x != nil && x.MaxPrice != nil {
+		return *x.MaxPrice
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetStrategy() Strategy {
+	if x != nil {
+		return x.Strategy
+	}
+	return Strategy_STRATEGY_UNSPECIFIED
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetFacets() bool {
+	if x != nil {
+		return x.Facets
+	}
+	return false
+}
+
+func (x *SearchRequest) GetFuzzy() bool {
+	if x != nil {
+		return x.Fuzzy
+	}
+	return false
+}
+
+func (x *SearchRequest) GetHighlight() bool {
+	if x != nil {
+		return x.Highlight
+	}
+	return false
+}
+
+type SearchResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Hits            []*Hit                 `protobuf:"bytes,1,rep,name=hits,proto3" json:"hits,omitempty"`
+	TotalFound      int64                  `protobuf:"varint,2,opt,name=total_found,json=totalFound,proto3" json:"total_found,omitempty"`
+	ProductsChecked int64                  `protobuf:"varint,3,opt,name=products_checked,json=productsChecked,proto3" json:"products_checked,omitempty"`
+	Strategy        Strategy               `protobuf:"varint,4,opt,name=strategy,proto3,enum=productsearch.v1.Strategy" json:"strategy,omitempty"`
+	NextCursor      string                 `protobuf:"bytes,5,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	// Set only if requested.
+	Facets      *Facets       `protobuf:"bytes,6,opt,name=facets,proto3" json:"facets,omitempty"`
+	Corrections []*Correction `protobuf:"bytes,7,rep,name=corrections,proto3" json:"corrections,omitempty"`
+	Cached      bool          `protobuf:"varint,8,opt,name=cached,proto3" json:"cached,omitempty"`
+	TimedOut    bool          `protobuf:"varint,9,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	// Wall time the search took, e.g. "1.2ms".
+	SearchTime    string `protobuf:"bytes,10,opt,name=search_time,json=searchTime,proto3" json:"search_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_pb_product_search_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetHits() []*Hit {
+	if x != nil {
+		return x.Hits
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetTotalFound() int64 {
+	if x != nil {
+		return x.TotalFound
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetProductsChecked() int64 {
+	if x != nil {
+		return x.ProductsChecked
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetStrategy() Strategy {
+	if x != nil {
+		return x.Strategy
+	}
+	return Strategy_STRATEGY_UNSPECIFIED
+}
+
+func (x *SearchResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetFacets() *Facets {
+	if x != nil {
+		return x.Facets
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetCorrections() []*Correction {
+	if x != nil {
+		return x.Corrections
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *SearchResponse) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+func (x *SearchResponse) GetSearchTime() string {
+	if x != nil {
+		return x.SearchTime
+	}
+	return ""
+}
+
+type Hit struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Product *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Score   float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	// Keyed by field name; set only if requested.
+	Highlight     map[string]*Fragment `protobuf:"bytes,3,rep,name=highlight,proto3" json:"highlight,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Hit) Reset() {
+	*x = Hit{}
+	mi := &file_pb_product_search_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Hit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hit) ProtoMessage() {}
+
+func (x *Hit) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hit.ProtoReflect.Descriptor instead.
+func (*Hit) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Hit) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *Hit) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Hit) GetHighlight() map[string]*Fragment {
+	if x != nil {
+		return x.Highlight
+	}
+	return nil
+}
+
+// Fragment shows where the query matched one field of a hit.
+type Fragment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The field, HTML-escaped, with matched tokens wrapped in <em></em>.
+	Text string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	// Byte offsets of the matched tokens in the unescaped field value.
+	Matches       []*Span `protobuf:"bytes,2,rep,name=matches,proto3" json:"matches,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Fragment) Reset() {
+	*x = Fragment{}
+	mi := &file_pb_product_search_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Fragment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Fragment) ProtoMessage() {}
+
+func (x *Fragment) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Fragment.ProtoReflect.Descriptor instead.
+func (*Fragment) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Fragment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Fragment) GetMatches() []*Span {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+// Span is a [start, end) byte range.
+type Span struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int32                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Span) Reset() {
+	*x = Span{}
+	mi := &file_pb_product_search_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Span) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Span) ProtoMessage() {}
+
+func (x *Span) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Span.ProtoReflect.Descriptor instead.
+func (*Span) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Span) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Span) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+type Facets struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Categories    map[string]int64       `protobuf:"bytes,1,rep,name=categories,proto3" json:"categories,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Brands        map[string]int64       `protobuf:"bytes,2,rep,name=brands,proto3" json:"brands,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Prices        []*PriceBucket         `protobuf:"bytes,3,rep,name=prices,proto3" json:"prices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Facets) Reset() {
+	*x = Facets{}
+	mi := &file_pb_product_search_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Facets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Facets) ProtoMessage() {}
+
+func (x *Facets) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Facets.ProtoReflect.Descriptor instead.
+func (*Facets) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Facets) GetCategories() map[string]int64 {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *Facets) GetBrands() map[string]int64 {
+	if x != nil {
+		return x.Brands
+	}
+	return nil
+}
+
+func (x *Facets) GetPrices() []*PriceBucket {
+	if x != nil {
+		return x.Prices
+	}
+	return nil
+}
+
+// PriceBucket counts matching products priced in [min, max). An unset
+// max means the bucket has no upper bound.
+type PriceBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Min           float64                `protobuf:"fixed64,1,opt,name=min,proto3" json:"min,omitempty"`
+	Max           *float64               `protobuf:"fixed64,2,opt,name=max,proto3,oneof" json:"max,omitempty"`
+	Count         int64                  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PriceBucket) Reset() {
+	*x = PriceBucket{}
+	mi := &file_pb_product_search_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PriceBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PriceBucket) ProtoMessage() {}
+
+func (x *PriceBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PriceBucket.ProtoReflect.Descriptor instead.
+func (*PriceBucket) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PriceBucket) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *PriceBucket) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *PriceBucket) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type Correction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Term          string                 `protobuf:"bytes,1,opt,name=term,proto3" json:"term,omitempty"`
+	Corrected     string                 `protobuf:"bytes,2,opt,name=corrected,proto3" json:"corrected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Correction) Reset() {
+	*x = Correction{}
+	mi := &file_pb_product_search_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Correction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Correction) ProtoMessage() {}
+
+func (x *Correction) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Correction.ProtoReflect.Descriptor instead.
+func (*Correction) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Correction) GetTerm() string {
+	if x != nil {
+		return x.Term
+	}
+	return ""
+}
+
+func (x *Correction) GetCorrected() string {
+	if x != nil {
+		return x.Corrected
+	}
+	return ""
+}
+
+type GetProductRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductRequest) Reset() {
+	*x = GetProductRequest{}
+	mi := &file_pb_product_search_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductRequest) ProtoMessage() {}
+
+func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductRequest.ProtoReflect.Descriptor instead.
+func (*GetProductRequest) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetProductRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ExportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportRequest) Reset() {
+	*x = ExportRequest{}
+	mi := &file_pb_product_search_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportRequest) ProtoMessage() {}
+
+func (x *ExportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_product_search_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportRequest.ProtoReflect.Descriptor instead.
+func (*ExportRequest) Descriptor() ([]byte, []int) {
+	return file_pb_product_search_proto_rawDescGZIP(), []int{10}
+}
+
+var File_pb_product_search_proto protoreflect.FileDescriptor
+
+const file_pb_product_search_proto_rawDesc = "" +
+	"\n" +
+	"\x17pb/product_search.proto\x12\x10productsearch.v1\"\x97\x01\n" +
+	"\aProduct\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1a\n" +
+	"\bcategory\x18\x03 \x01(\tR\bcategory\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x14\n" +
+	"\x05brand\x18\x05 \x01(\tR\x05brand\x12\x14\n" +
+	"\x05price\x18\x06 \x01(\x01R\x05price\"\x81\x03\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05brand\x18\x03 \x01(\tR\x05brand\x12 \n" +
+	"\tmin_price\x18\x04 \x01(\x01H\x00R\bminPrice\x88\x01\x01\x12 \n" +
+	"\tmax_price\x18\x05 \x01(\x01H\x01R\bmaxPrice\x88\x01\x01\x126\n" +
+	"\bstrategy\x18\x06 \x01(\x0e2\x1a.productsearch.v1.StrategyR\bstrategy\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\b \x01(\x05R\x06offset\x12\x16\n" +
+	"\x06cursor\x18\t \x01(\tR\x06cursor\x12\x16\n" +
+	"\x06facets\x18\n" +
+	" \x01(\bR\x06facets\x12\x14\n" +
+	"\x05fuzzy\x18\v \x01(\bR\x05fuzzy\x12\x1c\n" +
+	"\thighlight\x18\f \x01(\bR\thighlightB\f\n" +
+	"\n" +
+	"_min_priceB\f\n" +
+	"\n" +
+	"_max_price\"\xa8\x03\n" +
+	"\x0eSearchResponse\x12)\n" +
+	"\x04hits\x18\x01 \x03(\v2\x15.productsearch.v1.HitR\x04hits\x12\x1f\n" +
+	"\vtotal_found\x18\x02 \x01(\x03R\n" +
+	"totalFound\x12)\n" +
+	"\x10products_checked\x18\x03 \x01(\x03R\x0fproductsChecked\x126\n" +
+	"\bstrategy\x18\x04 \x01(\x0e2\x1a.productsearch.v1.StrategyR\bstrategy\x12\x1f\n" +
+	"\vnext_cursor\x18\x05 \x01(\tR\n" +
+	"nextCursor\x120\n" +
+	"\x06facets\x18\x06 \x01(\v2\x18.productsearch.v1.FacetsR\x06facets\x12>\n" +
+	"\vcorrections\x18\a \x03(\v2\x1c.productsearch.v1.CorrectionR\vcorrections\x12\x16\n" +
+	"\x06cached\x18\b \x01(\bR\x06cached\x12\x1b\n" +
+	"\ttimed_out\x18\t \x01(\bR\btimedOut\x12\x1f\n" +
+	"\vsearch_time\x18\n" +
+	" \x01(\tR\n" +
+	"searchTime\"\xee\x01\n" +
+	"\x03Hit\x123\n" +
+	"\aproduct\x18\x01 \x01(\v2\x19.productsearch.v1.ProductR\aproduct\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\x12B\n" +
+	"\thighlight\x18\x03 \x03(\v2$.productsearch.v1.Hit.HighlightEntryR\thighlight\x1aX\n" +
+	"\x0eHighlightEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.productsearch.v1.FragmentR\x05value:\x028\x01\"P\n" +
+	"\bFragment\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x120\n" +
+	"\amatches\x18\x02 \x03(\v2\x16.productsearch.v1.SpanR\amatches\".\n" +
+	"\x04Span\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x05R\x03end\"\xc1\x02\n" +
+	"\x06Facets\x12H\n" +
+	"\n" +
+	"categories\x18\x01 \x03(\v2(.productsearch.v1.Facets.CategoriesEntryR\n" +
+	"categories\x12<\n" +
+	"\x06brands\x18\x02 \x03(\v2$.productsearch.v1.Facets.BrandsEntryR\x06brands\x125\n" +
+	"\x06prices\x18\x03 \x03(\v2\x1d.productsearch.v1.PriceBucketR\x06prices\x1a=\n" +
+	"\x0fCategoriesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a9\n" +
+	"\vBrandsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"T\n" +
+	"\vPriceBucket\x12\x10\n" +
+	"\x03min\x18\x01 \x01(\x01R\x03min\x12\x15\n" +
+	"\x03max\x18\x02 \x01(\x01H\x00R\x03max\x88\x01\x01\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x03R\x05countB\x06\n" +
+	"\x04_max\">\n" +
+	"\n" +
+	"Correction\x12\x12\n" +
+	"\x04term\x18\x01 \x01(\tR\x04term\x12\x1c\n" +
+	"\tcorrected\x18\x02 \x01(\tR\tcorrected\"#\n" +
+	"\x11GetProductRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x0f\n" +
+	"\rExportRequest*K\n" +
+	"\bStrategy\x12\x18\n" +
+	"\x14STRATEGY_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSTRATEGY_INDEX\x10\x01\x12\x11\n" +
+	"\rSTRATEGY_SCAN\x10\x022\xf2\x01\n" +
+	"\rProductSearch\x12K\n" +
+	"\x06Search\x12\x1f.productsearch.v1.SearchRequest\x1a .productsearch.v1.SearchResponse\x12L\n" +
+	"\n" +
+	"GetProduct\x12#.productsearch.v1.GetProductRequest\x1a\x19.productsearch.v1.Product\x12F\n" +
+	"\x06Export\x12\x1f.productsearch.v1.ExportRequest\x1a\x19.productsearch.v1.Product0\x01B\x13Z\x11product-search/pbb\x06proto3"
+
+var (
+	file_pb_product_search_proto_rawDescOnce sync.Once
+	file_pb_product_search_proto_rawDescData []byte
+)
+
+func file_pb_product_search_proto_rawDescGZIP() []byte {
+	file_pb_product_search_proto_rawDescOnce.Do(func() {
+		file_pb_product_search_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pb_product_search_proto_rawDesc), len(file_pb_product_search_proto_rawDesc)))
+	})
+	return file_pb_product_search_proto_rawDescData
+}
+
+var file_pb_product_search_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pb_product_search_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_pb_product_search_proto_goTypes = []any{
+	(Strategy)(0),             // 0: productsearch.v1.Strategy
+	(*Product)(nil),           // 1: productsearch.v1.Product
+	(*SearchRequest)(nil),     // 2: productsearch.v1.SearchRequest
+	(*SearchResponse)(nil),    // 3: productsearch.v1.SearchResponse
+	(*Hit)(nil),               // 4: productsearch.v1.Hit
+	(*Fragment)(nil),          // 5: productsearch.v1.Fragment
+	(*Span)(nil),              // 6: productsearch.v1.Span
+	(*Facets)(nil),            // 7: productsearch.v1.Facets
+	(*PriceBucket)(nil),       // 8: productsearch.v1.PriceBucket
+	(*Correction)(nil),        // 9: productsearch.v1.Correction
+	(*GetProductRequest)(nil), // 10: productsearch.v1.GetProductRequest
+	(*ExportRequest)(nil),     // 11: productsearch.v1.ExportRequest
+	nil,                       // 12: productsearch.v1.Hit.HighlightEntry
+	nil,                       // 13: productsearch.v1.Facets.CategoriesEntry
+	nil,                       // 14: productsearch.v1.Facets.BrandsEntry
+}
+var file_pb_product_search_proto_depIdxs = []int32{
+	0,  // 0: productsearch.v1.SearchRequest.strategy:type_name -> productsearch.v1.Strategy
+	4,  // 1: productsearch.v1.SearchResponse.hits:type_name -> productsearch.v1.Hit
+	0,  // 2: productsearch.v1.SearchResponse.strategy:type_name -> productsearch.v1.Strategy
+	7,  // 3: productsearch.v1.SearchResponse.facets:type_name -> productsearch.v1.Facets
+	9,  // 4: productsearch.v1.SearchResponse.corrections:type_name -> productsearch.v1.Correction
+	1,  // 5: productsearch.v1.Hit.product:type_name -> productsearch.v1.Product
+	12, // 6: productsearch.v1.Hit.highlight:type_name -> productsearch.v1.Hit.HighlightEntry
+	6,  // 7: productsearch.v1.Fragment.matches:type_name -> productsearch.v1.Span
+	13, // 8: productsearch.v1.Facets.categories:type_name -> productsearch.v1.Facets.CategoriesEntry
+	14, // 9: productsearch.v1.Facets.brands:type_name -> productsearch.v1.Facets.BrandsEntry
+	8,  // 10: productsearch.v1.Facets.prices:type_name -> productsearch.v1.PriceBucket
+	5,  // 11: productsearch.v1.Hit.HighlightEntry.value:type_name -> productsearch.v1.Fragment
+	2,  // 12: productsearch.v1.ProductSearch.Search:input_type -> productsearch.v1.SearchRequest
+	10, // 13: productsearch.v1.ProductSearch.GetProduct:input_type -> productsearch.v1.GetProductRequest
+	11, // 14: productsearch.v1.ProductSearch.Export:input_type -> productsearch.v1.ExportRequest
+	3,  // 15: productsearch.v1.ProductSearch.Search:output_type -> productsearch.v1.SearchResponse
+	1,  // 16: productsearch.v1.ProductSearch.GetProduct:output_type -> productsearch.v1.Product
+	1,  // 17: productsearch.v1.ProductSearch.Export:output_type -> productsearch.v1.Product
+	15, // [15:18] is the sub-list for method output_type
+	12, // [12:15] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_pb_product_search_proto_init() }
+func file_pb_product_search_proto_init() {
+	if File_pb_product_search_proto != nil {
+		return
+	}
+	file_pb_product_search_proto_msgTypes[1].OneofWrappers = []any{}
+	file_pb_product_search_proto_msgTypes[7].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pb_product_search_proto_rawDesc), len(file_pb_product_search_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pb_product_search_proto_goTypes,
+		DependencyIndexes: file_pb_product_search_proto_depIdxs,
+		EnumInfos:         file_pb_product_search_proto_enumTypes,
+		MessageInfos:      file_pb_product_search_proto_msgTypes,
+	}.Build()
+	File_pb_product_search_proto = out.File
+	file_pb_product_search_proto_goTypes = nil
+	file_pb_product_search_proto_depIdxs = nil
+}
@@ -0,0 +1,220 @@
+// The product search gRPC API. It mirrors the HTTP/JSON endpoints
+// served by package handler.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: pb/product_search.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ProductSearch_Search_FullMethodName     = "/productsearch.v1.ProductSearch/Search"
+	ProductSearch_GetProduct_FullMethodName = "/productsearch.v1.ProductSearch/GetProduct"
+	ProductSearch_Export_FullMethodName     = "/productsearch.v1.ProductSearch/Export"
+)
+
+// ProductSearchClient is the client API for ProductSearch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ProductSearch searches and reads the product catalog.
+type ProductSearchClient interface {
+	// Search runs a query in the search query language, like
+	// GET /products/search. The call's deadline bounds the search, which
+	// then returns the hits found so far with timed_out set; without a
+	// deadline the server's default applies.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// GetProduct returns one product, or NOT_FOUND.
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// Export streams every product in ascending ID order.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Product], error)
+}
+
+type productSearchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProductSearchClient(cc grpc.ClientConnInterface) ProductSearchClient {
+	return &productSearchClient{cc}
+}
+
+func (c *productSearchClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, ProductSearch_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productSearchClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductSearch_GetProduct_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productSearchClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Product], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ProductSearch_ServiceDesc.Streams[0], ProductSearch_Export_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportRequest, Product]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProductSearch_ExportClient = grpc.ServerStreamingClient[Product]
+
+// ProductSearchServer is the server API for ProductSearch service.
+// All implementations must embed UnimplementedProductSearchServer
+// for forward compatibility.
+//
+// ProductSearch searches and reads the product catalog.
+type ProductSearchServer interface {
+	// Search runs a query in the search query language, like
+	// GET /products/search. The call's deadline bounds the search, which
+	// then returns the hits found so far with timed_out set; without a
+	// deadline the server's default applies.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// GetProduct returns one product, or NOT_FOUND.
+	GetProduct(context.Context, *GetProductRequest) (*Product, error)
+	// Export streams every product in ascending ID order.
+	Export(*ExportRequest, grpc.ServerStreamingServer[Product]) error
+	mustEmbedUnimplementedProductSearchServer()
+}
+
+// UnimplementedProductSearchServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedProductSearchServer struct{}
+
+func (UnimplementedProductSearchServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedProductSearchServer) GetProduct(context.Context, *GetProductRequest) (*Product, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetProduct not implemented")
+}
+func (UnimplementedProductSearchServer) Export(*ExportRequest, grpc.ServerStreamingServer[Product]) error {
+	return status.Error(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedProductSearchServer) mustEmbedUnimplementedProductSearchServer() {}
+func (UnimplementedProductSearchServer) testEmbeddedByValue()                       {}
+
+// UnsafeProductSearchServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProductSearchServer will
+// result in compilation errors.
+type UnsafeProductSearchServer interface {
+	mustEmbedUnimplementedProductSearchServer()
+}
+
+func RegisterProductSearchServer(s grpc.ServiceRegistrar, srv ProductSearchServer) {
+	// If the following call panics, it indicates UnimplementedProductSearchServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ProductSearch_ServiceDesc, srv)
+}
+
+func _ProductSearch_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductSearchServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductSearch_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductSearchServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductSearch_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductSearchServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductSearch_GetProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductSearchServer).GetProduct(ctx, req.(*GetProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductSearch_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProductSearchServer).Export(m, &grpc.GenericServerStream[ExportRequest, Product]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ProductSearch_ExportServer = grpc.ServerStreamingServer[Product]
+
+// ProductSearch_ServiceDesc is the grpc.ServiceDesc for ProductSearch service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ProductSearch_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "productsearch.v1.ProductSearch",
+	HandlerType: (*ProductSearchServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _ProductSearch_Search_Handler,
+		},
+		{
+			MethodName: "GetProduct",
+			Handler:    _ProductSearch_GetProduct_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       _ProductSearch_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pb/product_search.proto",
+}
@@ -0,0 +1,378 @@
+package search
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"product-search/model"
+)
+
+// maxCachedHits keeps very broad queries, such as a lone negation, out
+// of the cache; they would crowd out everything else.
+const maxCachedHits = 50000
+
+// matches is everything an Execute call derives from the query and
+// filters alone, before paging. Once cached it is shared by concurrent
+// requests and must not be modified, except that facets are filled in
+// on first request.
+type matches struct {
+	query       node     // with fuzzy corrections applied
+	hits        []scored // ranked
+	checked     int
+	lastChecked int // highest ID the scan strategy checked
+	corrections []Correction
+	timedOut    bool // the search stopped early; never cached
+	facets      atomic.Pointer[Facets]
+}
+
+// CacheStats counts cache activity since the Cache was created.
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+}
+
+// Cache is an LRU cache of search matches with a time-to-live. It
+// implements store.Observer: a catalog write drops exactly the entries
+// whose results it could change, those that held the product or whose
+// query and filters match its new version. Scan entries are also
+// dropped by writes inside the window they checked, and fuzzy entries
+// by any write, since the vocabulary their corrections came from has
+// changed. Other entries keep their scores until they expire, although
+// a write shifts the collection statistics BM25F uses slightly. Safe
+// for concurrent use.
+//
+// Observers are called under the store's write lock, so the Cache only
+// queues writes there and applies them on its next use, before that
+// use can see a stale entry. Entries are indexed by a token or filter
+// value that every product they match carries, so a write is only
+// matched against the entries it could join; the rest are passed over
+// with a lookup of the product's ID in their hits.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     list.List                           // of *cacheEntry, most recently used first
+	guarded map[string]map[*cacheEntry]struct{} // by guard key
+	open    map[*cacheEntry]struct{}            // index entries without a guard
+	pending []write                             // not yet applied
+	writes  uint64                              // catalog writes seen, to spot races with put
+	stats   CacheStats
+}
+
+// maxPending bounds the queue of writes not yet applied. A write that
+// would exceed it, as during a bulk import, clears the whole cache and
+// the queue instead.
+const maxPending = 4096
+
+type write struct {
+	product model.Product
+	deleted bool
+}
+
+type cacheEntry struct {
+	key      string
+	expires  time.Time
+	strategy Strategy
+	filters  Filters
+	fuzzy    bool
+	guards   []string // see guards; nil for an open entry
+	ids      []int    // sorted IDs of m.hits
+	m        *matches
+}
+
+// NewCache creates a Cache holding up to size entries for at most ttl
+// each.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		guarded: make(map[string]map[*cacheEntry]struct{}),
+		open:    make(map[*cacheEntry]struct{}),
+	}
+}
+
+// Stats returns the cache counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apply()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	return s
+}
+
+// cacheKey identifies the matches of a request: its strategy, its
+// query in canonical form, its filters and whether it is fuzzy.
+func (req Request) cacheKey(query node) string {
+	q := ""
+	if query != nil {
+		q = query.String()
+	}
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%t", req.Strategy, q, req.Filters.key(), req.Fuzzy)
+}
+
+// get returns the live entry for key. A nil Cache always misses.
+func (c *Cache) get(key string) (*matches, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apply()
+
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*cacheEntry).expires) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry).m, true
+}
+
+// version returns a token to pass to put, taken before computing the
+// matches to be cached.
+func (c *Cache) version() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writes
+}
+
+// put caches m under key unless the catalog was written since version
+// was taken, in which case m may already be stale.
+func (c *Cache) put(key string, req Request, m *matches, version uint64) {
+	if c == nil || len(m.hits) > maxCachedHits {
+		return
+	}
+	ids := make([]int, len(m.hits))
+	for i, h := range m.hits {
+		ids[i] = h.id
+	}
+	slices.Sort(ids)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writes != version {
+		return
+	}
+	c.apply()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &cacheEntry{
+		key:      key,
+		expires:  time.Now().Add(c.ttl),
+		strategy: req.Strategy,
+		filters:  req.Filters,
+		fuzzy:    req.Fuzzy,
+		ids:      ids,
+		m:        m,
+	}
+	if !e.fuzzy && e.strategy != StrategyScan {
+		e.guards = guards(m.query, req.Filters)
+		for _, g := range e.guards {
+			if c.guarded[g] == nil {
+				c.guarded[g] = make(map[*cacheEntry]struct{})
+			}
+			c.guarded[g][e] = struct{}{}
+		}
+		if e.guards == nil {
+			c.open[e] = struct{}{}
+		}
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry. Callers must hold c.mu.
+func (c *Cache) remove(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	for _, g := range e.guards {
+		delete(c.guarded[g], e)
+		if len(c.guarded[g]) == 0 {
+			delete(c.guarded, g)
+		}
+	}
+	delete(c.open, e)
+}
+
+// ProductPut queues the write, to drop the entries it could change.
+func (c *Cache) ProductPut(p model.Product) { c.queue(write{product: p}) }
+
+// ProductDeleted queues the deletion, to drop the entries that held
+// the product.
+func (c *Cache) ProductDeleted(p model.Product) { c.queue(write{product: p, deleted: true}) }
+
+func (c *Cache) queue(w write) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	if c.pending == nil && c.lru.Len() == 0 {
+		return // nothing to invalidate
+	}
+	if len(c.pending) >= maxPending {
+		c.clear()
+		return
+	}
+	c.pending = append(c.pending, w)
+}
+
+// clear drops every entry and queued write. Callers must hold c.mu.
+func (c *Cache) clear() {
+	c.stats.Invalidations += uint64(c.lru.Len())
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Front())
+	}
+	c.pending = nil
+}
+
+// apply drops the entries the queued writes could change. Callers must
+// hold c.mu.
+func (c *Cache) apply() {
+	if len(c.pending) == 0 {
+		return
+	}
+	for _, w := range c.pending {
+		if c.lru.Len() == 0 {
+			break
+		}
+		c.invalidate(w)
+	}
+	c.pending = nil
+}
+
+// invalidate drops the entries w could change. Every entry is checked
+// for holding the product, so applying the queue costs O(entries ×
+// writes); maxPending keeps that bounded. Callers must hold c.mu.
+func (c *Cache) invalidate(w write) {
+	drop := func(e *cacheEntry) {
+		if el, ok := c.entries[e.key]; ok && el.Value == e {
+			c.remove(el)
+			c.stats.Invalidations++
+		}
+	}
+	id := w.product.ID
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*cacheEntry)
+		switch {
+		case e.fuzzy, e.strategy == StrategyScan && (e.m.checked < MaxCheck || id <= e.m.lastChecked):
+			drop(e)
+		default:
+			if _, held := slices.BinarySearch(e.ids, id); held {
+				drop(e)
+			}
+		}
+		el = next
+	}
+	if w.deleted {
+		return
+	}
+
+	d := newDoc(w.product)
+	for _, g := range docGuards(d) {
+		for e := range c.guarded[g] {
+			if e.matches(d) {
+				drop(e)
+			}
+		}
+	}
+	for e := range c.open {
+		if e.matches(d) {
+			drop(e)
+		}
+	}
+}
+
+// matches reports whether d matches the entry's query and filters.
+func (e *cacheEntry) matches(d *doc) bool {
+	return (e.m.query == nil || e.m.query.match(d)) && e.filters.match(d.Product)
+}
+
+// guards returns keys of which every product matching query and
+// filters carries at least one among its docGuards, or nil if there
+// are none: a token the query requires, else the category or brand
+// filtered on.
+func guards(query node, filters Filters) []string {
+	if query != nil {
+		if toks, ok := required(query); ok {
+			keys := make([]string, len(toks))
+			for i, t := range toks {
+				keys[i] = "t\x00" + t
+			}
+			return keys
+		}
+	}
+	switch {
+	case filters.Category != "":
+		return []string{"c\x00" + strings.ToLower(filters.Category)}
+	case filters.Brand != "":
+		return []string{"b\x00" + strings.ToLower(filters.Brand)}
+	}
+	return nil
+}
+
+// required returns tokens of which every product matching n contains
+// at least one, if there are such tokens. A query matching nothing
+// requires an empty set.
+func required(n node) ([]string, bool) {
+	switch n := n.(type) {
+	case *termNode:
+		return n.tokens[:1], true
+	case andNode:
+		for _, x := range n {
+			if toks, ok := required(x); ok {
+				return toks, true
+			}
+		}
+	case orNode:
+		var toks []string
+		for _, x := range n {
+			more, ok := required(x)
+			if !ok {
+				return nil, false
+			}
+			toks = append(toks, more...)
+		}
+		return distinct(toks), true
+	case noneNode:
+		return []string{}, true
+	}
+	return nil, false
+}
+
+// docGuards returns the guard keys d carries: its tokens, category and
+// brand.
+func docGuards(d *doc) []string {
+	var toks []string
+	for _, f := range d.fields {
+		toks = append(toks, f...)
+	}
+	keys := []string{"c\x00" + strings.ToLower(d.Category), "b\x00" + strings.ToLower(d.Brand)}
+	for _, t := range distinct(toks) {
+		keys = append(keys, "t\x00"+t)
+	}
+	return keys
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// cachedEngine returns an engine with a cache over a small catalog.
+func cachedEngine(t testing.TB) (*Engine, *store.ProductStore) {
+	t.Helper()
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Red Widget", Category: "tools", Brand: "Acme", Price: 10})
+	s.Put(model.Product{ID: 2, Name: "Blue Widget", Category: "tools", Brand: "Bolt", Price: 20})
+	s.Put(model.Product{ID: 3, Name: "Green Gadget", Category: "toys", Brand: "Acme", Price: 30})
+	e := New(s, StrategyIndex)
+	e.UseCache(NewCache(100, time.Minute))
+	return e, s
+}
+
+// isCached runs req twice and reports whether the second run was
+// served from the cache, after the writes in between.
+func isCached(t *testing.T, e *Engine, req Request, between func()) bool {
+	t.Helper()
+	if _, err := e.Execute(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	between()
+	res, err := e.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Cached
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	price := 15.0
+	tests := []struct {
+		name  string
+		req   Request
+		write func(s *store.ProductStore)
+		kept  bool
+	}{
+		{"unrelated put", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Yellow Gadget"})
+		}, true},
+		{"matching put", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Yellow Widget"})
+		}, false},
+		{"match in another field", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Thing", Description: "Works with any widget"})
+		}, false},
+		{"update of a hit", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 1, Name: "Red Gadget"})
+		}, false},
+		{"delete of a hit", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Delete(2)
+		}, false},
+		{"delete of another product", Request{Query: "widget"}, func(s *store.ProductStore) {
+			s.Delete(3)
+		}, true},
+		{"or query, second branch", Request{Query: "widget OR sprocket"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Sprocket"})
+		}, false},
+		{"and query, other term only", Request{Query: "red widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Red Gadget"})
+		}, true},
+		{"phrase", Request{Query: `"red widget"`}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Widget, red"})
+		}, true},
+		{"filter only, matching", Request{Filters: Filters{Category: "TOOLS"}}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Hammer", Category: "tools"})
+		}, false},
+		{"filter only, other category", Request{Filters: Filters{Category: "tools"}}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Hammer", Category: "garden"})
+		}, true},
+		{"query and filter, filter fails", Request{Query: "widget", Filters: Filters{Brand: "acme"}}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Widget", Brand: "Bolt"})
+		}, true},
+		{"price filter only", Request{Filters: Filters{MaxPrice: &price}}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Cheap", Price: 5})
+		}, false},
+		{"negation", Request{Query: "-widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Sprocket"})
+		}, false},
+		{"negation, excluded put", Request{Query: "-widget"}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Widget"})
+		}, true},
+		{"fuzzy", Request{Query: "widget", Fuzzy: true}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Sprocket"})
+		}, false},
+		{"scan window", Request{Query: "widget", Strategy: StrategyScan}, func(s *store.ProductStore) {
+			s.Put(model.Product{ID: 10, Name: "Sprocket"})
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, s := cachedEngine(t)
+			if got := isCached(t, e, tt.req, func() { tt.write(s) }); got != tt.kept {
+				t.Errorf("cached after write = %v, want %v", got, tt.kept)
+			}
+		})
+	}
+}
+
+// TestCacheSeesWritesAtOnce checks that a queued write is applied
+// before the next search can be served a stale entry.
+func TestCacheSeesWritesAtOnce(t *testing.T) {
+	e, s := cachedEngine(t)
+	req := Request{Query: "widget"}
+	for i := range 100 {
+		s.Put(model.Product{ID: 100 + i, Name: fmt.Sprintf("Widget %d", i)})
+		res, err := e.Execute(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 3 + i; res.TotalFound != want {
+			t.Fatalf("after %d writes found %d, want %d", i+1, res.TotalFound, want)
+		}
+	}
+}
+
+// TestCacheClearsOnWriteFlood checks that more writes than are queued
+// clear the cache as they arrive rather than queueing without bound
+// until the next read.
+func TestCacheClearsOnWriteFlood(t *testing.T) {
+	e, s := cachedEngine(t)
+	if _, err := e.Execute(context.Background(), Request{Query: "widget"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 * maxPending {
+		s.Put(model.Product{ID: 100 + i, Name: "Sprocket"})
+		if n := len(e.cache.pending); n > maxPending {
+			t.Fatalf("%d writes queued, want at most %d", n, maxPending)
+		}
+	}
+	stats, _ := e.CacheStats()
+	if stats.Entries != 0 || stats.Invalidations != 1 {
+		t.Fatalf("got %d entries, %d invalidations; want the cache cleared", stats.Entries, stats.Invalidations)
+	}
+}
+
+// BenchmarkCacheWrites measures catalog writes against a full cache
+// of queries none of them affect, the case of a bulk import.
+func BenchmarkCacheWrites(b *testing.B) {
+	s := store.New()
+	for id := 1; id <= 2000; id++ {
+		s.Put(model.Product{ID: id, Name: fmt.Sprintf("Widget w%d", id%800), Description: "A sturdy widget for everyday use"})
+	}
+	e := New(s, StrategyIndex)
+	e.UseCache(NewCache(800, time.Hour))
+	for i := range 800 {
+		if _, err := e.Execute(context.Background(), Request{Query: fmt.Sprintf("w%d", i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	p := model.Product{Name: "Unrelated Gadget", Description: "Nothing any cached query asks for"}
+	b.ResetTimer()
+	for i := range b.N {
+		p.ID = 10000 + i
+		s.Put(p)
+		if i%1000 == 0 {
+			e.CacheStats() // applies the queued writes
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Cursor marks a position in a ranked result list. It records the ID
+// and score of the last hit served rather than an offset, and the next
+// page resumes right after that product wherever it now ranks. Writes
+// change every score, but products written concurrently ahead of the
+// cursor never shift or repeat the pages that follow it, and only
+// products whose own rank moves across the cursor are skipped or
+// repeated.
+type Cursor struct {
+	Query uint64  `json:"q"` // fingerprint of the request it continues
+	Score float64 `json:"s"`
+	ID    int     `json:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor decodes a token produced by Cursor.Encode.
+func ParseCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	return &c, nil
+}
+
+// CursorAfter returns the token continuing req's ranked result list
+// after h. It lets a caller that merges lists ranked by several Engines
+// for the same request hand out a cursor each of them accepts.
+// req.Strategy must be set.
+func (req Request) CursorAfter(h Hit) string {
+	return Cursor{Query: req.fingerprint(), Score: h.Score, ID: h.ID}.Encode()
+}
+
+// fingerprint identifies the result list a request ranks, so a cursor
+// cannot be replayed against a different query.
+func (req Request) fingerprint() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(req.Strategy))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.TrimSpace(req.Query))) // operators are case-sensitive
+	h.Write([]byte{0})
+	h.Write([]byte(req.Filters.key()))
+	if req.Fuzzy {
+		h.Write([]byte{0, 'f'})
+	}
+	return h.Sum64()
+}
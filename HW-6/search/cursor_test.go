@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// widgets returns a store holding n products matching "widget", with
+// names of differing lengths so their scores differ.
+func widgets(n int) *store.ProductStore {
+	s := store.New()
+	for id := 1; id <= n; id++ {
+		s.Put(model.Product{
+			ID:       id,
+			Name:     "Widget" + strings.Repeat(" deluxe", id%7),
+			Category: "tools",
+			Brand:    "Acme",
+			Price:    10,
+		})
+	}
+	return s
+}
+
+// TestCursorSurvivesWrites pages through a result list while the
+// catalog is written between pages. Every write moves every score, so
+// the cursor must resume from its product rather than its old score.
+func TestCursorSurvivesWrites(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cached=%v", cached), func(t *testing.T) {
+			s := widgets(200)
+			e := New(s, StrategyIndex)
+			if cached {
+				e.UseCache(NewCache(10, time.Minute))
+			}
+
+			seen := make(map[int]int)
+			deleted := make(map[int]bool)
+			next := 1000
+			req := Request{Query: "widget", Limit: 10}
+			for page := 0; ; page++ {
+				if page > 40 {
+					t.Fatal("paging did not end")
+				}
+				res, err := e.Execute(context.Background(), req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, h := range res.Products {
+					seen[h.ID]++
+				}
+				if res.NextCursor == "" {
+					break
+				}
+				if len(res.Products) == 0 {
+					t.Fatalf("page %d is empty but has a next cursor", page)
+				}
+
+				// Unrelated writes, a deletion of a product already
+				// served and an update of the cursor's own product.
+				for range 100 {
+					s.Put(model.Product{ID: next, Name: "Gadget", Description: strings.Repeat("long text ", next%5), Category: "toys"})
+					next++
+				}
+				first := res.Products[0].ID
+				s.Delete(first)
+				deleted[first] = true
+				last := res.Products[len(res.Products)-1].Product
+				last.Price++
+				s.Put(last)
+
+				if req.Cursor, err = ParseCursor(res.NextCursor); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for id := 1; id <= 200; id++ {
+				if n := seen[id]; n != 1 {
+					t.Errorf("product %d served %d times, want once", id, n)
+				}
+			}
+		})
+	}
+}
+
+// TestCursorAfterCursorProductDeleted checks that a page still resumes
+// near its place when the product the cursor points at is gone.
+func TestCursorAfterCursorProductDeleted(t *testing.T) {
+	s := widgets(50)
+	e := New(s, StrategyIndex)
+	res, err := e.Execute(context.Background(), Request{Query: "widget", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := ParseCursor(res.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Delete(cursor.ID)
+
+	res, err = e.Execute(context.Background(), Request{Query: "widget", Limit: 10, Cursor: cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Products) != 10 {
+		t.Fatalf("got %d products, want 10", len(res.Products))
+	}
+}
+
+func TestCursorRejectsOtherQuery(t *testing.T) {
+	e := New(widgets(30), StrategyIndex)
+	res, err := e.Execute(context.Background(), Request{Query: "widget", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, _ := ParseCursor(res.NextCursor)
+	if _, err := e.Execute(context.Background(), Request{Query: "deluxe", Limit: 10, Cursor: cursor}); err == nil {
+		t.Fatal("a cursor from another query was accepted")
+	}
+	if _, err := ParseCursor("!!"); err == nil {
+		t.Fatal("a malformed cursor was accepted")
+	}
+}
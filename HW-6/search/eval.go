@@ -0,0 +1,325 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// anyField scopes a term to every searchable field.
+const anyField field = -1
+
+// fieldNames are the names fields are scoped by in queries.
+var fieldNames = [numFields]string{
+	fieldName:        "name",
+	fieldCategory:    "category",
+	fieldBrand:       "brand",
+	fieldDescription: "description",
+}
+
+// node is a parsed query. It can be evaluated two ways: match tests a
+// single product, as the cache does to see whether a write affects a
+// result, and eval resolves the whole catalog through the inverted
+// index and the store's secondary indexes. Both agree on which products
+// match. The scan strategy matches by substring instead; see
+// scanMatch.
+type node interface {
+	match(d *doc) bool
+	eval(c *evalContext) []int // sorted product IDs
+	String() string
+}
+
+// evalContext carries what eval needs and caches the ID universe that
+// negations subtract from. Once ctx is done, evaluation winds down
+// early; its result is then meaningless, since a partial operand of a
+// negation would add matches rather than lose them.
+type evalContext struct {
+	ctx      context.Context
+	index    *Index
+	store    store.Store
+	universe []int
+}
+
+func (c *evalContext) all() []int {
+	if c.universe == nil {
+		c.universe = c.index.allIDs()
+	}
+	return c.universe
+}
+
+// doc is a product with its searchable fields tokenized once, so it
+// can be tested against a query, or many, term by term.
+type doc struct {
+	model.Product
+	fields [numFields][]string
+}
+
+func newDoc(p model.Product) *doc {
+	d := &doc{Product: p}
+	for f, text := range productFields(p) {
+		d.fields[f] = tokenize(text)
+	}
+	return d
+}
+
+type (
+	// termNode matches products containing tokens consecutively in
+	// one field, or in any field when field is anyField. text is the
+	// term as written, lower-cased, which the scan strategy matches
+	// as a substring.
+	termNode struct {
+		field  field
+		tokens []string
+		text   string
+	}
+
+	// priceNode matches products priced in [lo, hi].
+	priceNode struct{ lo, hi float64 }
+
+	andNode []node
+	orNode  []node
+	notNode struct{ x node }
+
+	// noneNode matches nothing.
+	noneNode struct{}
+)
+
+// newTermNode returns a term for text, or nil if text has no tokens.
+func newTermNode(f field, text string) node {
+	toks := tokenize(text)
+	if len(toks) == 0 {
+		return nil
+	}
+	return &termNode{field: f, tokens: toks, text: strings.ToLower(strings.TrimSpace(text))}
+}
+
+func (n *termNode) match(d *doc) bool {
+	if n.field != anyField {
+		return containsRun(d.fields[n.field], n.tokens)
+	}
+	for _, toks := range d.fields {
+		if containsRun(toks, n.tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *termNode) eval(c *evalContext) []int {
+	ids := c.index.ids(n.tokens[0], n.field)
+	for _, t := range n.tokens[1:] {
+		ids = intersect(ids, c.index.ids(t, n.field))
+	}
+	if len(n.tokens) == 1 {
+		return ids
+	}
+	// The index has no positions, so check adjacency on the products.
+	out := ids[:0]
+	for i, id := range ids {
+		if i%checkEvery == 0 && c.ctx.Err() != nil {
+			break
+		}
+		if p, ok := c.store.Get(id); ok && n.match(newDoc(p)) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// String writes the term as written, so terms with the same tokens
+// but different punctuation, which scan tells apart, differ.
+func (n *termNode) String() string {
+	s := n.text
+	if s != n.tokens[0] {
+		s = `"` + s + `"`
+	}
+	if n.field != anyField {
+		s = fieldNames[n.field] + ":" + s
+	}
+	return s
+}
+
+func (n priceNode) match(d *doc) bool { return d.Price >= n.lo && d.Price <= n.hi }
+
+func (n priceNode) eval(c *evalContext) []int { return c.store.ByPriceRange(n.lo, n.hi) }
+
+func (n priceNode) String() string { return fmt.Sprintf("price:%g..%g", n.lo, n.hi) }
+
+func (n andNode) match(d *doc) bool {
+	for _, x := range n {
+		if !x.match(d) {
+			return false
+		}
+	}
+	return true
+}
+
+// eval intersects the positive operands, smallest first, then
+// subtracts the negated ones, so "a -b" never builds the complement
+// of b.
+func (n andNode) eval(c *evalContext) []int {
+	var pos, neg [][]int
+	for _, x := range n {
+		if not, ok := x.(notNode); ok {
+			neg = append(neg, not.x.eval(c))
+		} else {
+			pos = append(pos, x.eval(c))
+		}
+	}
+
+	var ids []int
+	if len(pos) == 0 {
+		ids = c.all() // not modified: difference copies
+	} else {
+		slices.SortFunc(pos, func(a, b []int) int { return len(a) - len(b) })
+		ids = pos[0]
+		for _, other := range pos[1:] {
+			ids = intersect(ids, other)
+		}
+	}
+	for _, other := range neg {
+		ids = difference(ids, other)
+	}
+	return ids
+}
+
+func (n andNode) String() string { return joinNodes(n, " AND ") }
+
+func (n orNode) match(d *doc) bool {
+	for _, x := range n {
+		if x.match(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n orNode) eval(c *evalContext) []int {
+	var ids []int
+	for _, x := range n {
+		ids = union(ids, x.eval(c))
+	}
+	return ids
+}
+
+func (n orNode) String() string { return joinNodes(n, " OR ") }
+
+func (n notNode) match(d *doc) bool { return !n.x.match(d) }
+
+func (n notNode) eval(c *evalContext) []int { return difference(c.all(), n.x.eval(c)) }
+
+func (n notNode) String() string { return "-" + n.x.String() }
+
+func (noneNode) match(*doc) bool { return false }
+
+func (noneNode) eval(*evalContext) []int { return nil }
+
+func (noneNode) String() string { return "()" }
+
+func joinNodes(nodes []node, sep string) string {
+	parts := make([]string, len(nodes))
+	for i, x := range nodes {
+		parts[i] = x.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// visitTerms calls fn for every term in n, telling it whether the
+// term sits under a negation.
+func visitTerms(n node, negated bool, fn func(t *termNode, negated bool)) {
+	switch n := n.(type) {
+	case *termNode:
+		fn(n, negated)
+	case andNode:
+		for _, x := range n {
+			visitTerms(x, negated, fn)
+		}
+	case orNode:
+		for _, x := range n {
+			visitTerms(x, negated, fn)
+		}
+	case notNode:
+		visitTerms(n.x, !negated, fn)
+	}
+}
+
+// terms returns the distinct tokens of the terms n requires, the ones
+// not under a negation, which are what hits are scored by.
+func terms(n node) []string {
+	var out []string
+	visitTerms(n, false, func(t *termNode, negated bool) {
+		if !negated {
+			out = append(out, t.tokens...)
+		}
+	})
+	return distinct(out)
+}
+
+// plainTerms returns the tokens of n if it is only a conjunction of
+// unscoped single-token terms, which Index.Lookup answers directly.
+func plainTerms(n node) ([]string, bool) {
+	nodes := []node{n}
+	if and, ok := n.(andNode); ok {
+		nodes = and
+	}
+	var toks []string
+	for _, x := range nodes {
+		t, ok := x.(*termNode)
+		if !ok || t.field != anyField || len(t.tokens) != 1 {
+			return nil, false
+		}
+		toks = append(toks, t.tokens[0])
+	}
+	return distinct(toks), true
+}
+
+// containsRun reports whether run occurs as consecutive tokens in toks.
+func containsRun(toks, run []string) bool {
+	for i := 0; i+len(run) <= len(toks); i++ {
+		if slices.Equal(toks[i:i+len(run)], run) {
+			return true
+		}
+	}
+	return false
+}
+
+// union returns the IDs present in either sorted slice.
+func union(a, b []int) []int {
+	out := make([]int, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// difference returns the IDs of sorted slice a that are not in b.
+func difference(a, b []int) []int {
+	out := make([]int, 0, len(a))
+	j := 0
+	for _, id := range a {
+		for j < len(b) && b[j] < id {
+			j++
+		}
+		if j == len(b) || b[j] != id {
+			out = append(out, id)
+		}
+	}
+	return out
+}
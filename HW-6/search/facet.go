@@ -0,0 +1,85 @@
+package search
+
+import (
+	"context"
+	"math"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// priceEdges are the lower bounds of the price histogram buckets. The
+// last bucket is open-ended.
+var priceEdges = []float64{0, 10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// Facets aggregates attributes over every product matching a search,
+// not just the page returned, so clients can render filter sidebars.
+type Facets struct {
+	Categories map[string]int `json:"categories"`
+	Brands     map[string]int `json:"brands"`
+	Prices     []PriceBucket  `json:"prices"`
+}
+
+// PriceBucket counts matching products priced in [Min, Max). A nil Max
+// means the bucket has no upper bound.
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+// facets tallies the category, brand and price bucket of every hit
+// from the attributes the index holds, so no product is loaded. If ctx
+// is done first, the counts cover only the hits tallied so far.
+func (e *Engine) facets(ctx context.Context, hits []scored) *Facets {
+	ctx, span := tracer.Start(ctx, "search.facets", trace.WithAttributes(attribute.Int("search.hits", len(hits))))
+	defer span.End()
+	f := &Facets{
+		Categories: make(map[string]int),
+		Brands:     make(map[string]int),
+		Prices:     make([]PriceBucket, len(priceEdges)),
+	}
+	for i, lo := range priceEdges {
+		f.Prices[i].Min = lo
+		if i+1 < len(priceEdges) {
+			f.Prices[i].Max = &priceEdges[i+1]
+		}
+	}
+	e.index.tally(ctx, hits, f)
+	return f
+}
+
+// priceBucket returns the index of the bucket containing price, or -1
+// for prices below the first edge.
+func priceBucket(price float64) int {
+	if math.IsNaN(price) {
+		return -1
+	}
+	for i := len(priceEdges) - 1; i >= 0; i-- {
+		if price >= priceEdges[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// tally adds the attributes of each indexed hit to f. Hits deleted
+// since they matched are skipped. If ctx is done first, it stops early.
+func (ix *Index) tally(ctx context.Context, hits []scored, f *Facets) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for i, h := range hits {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			return
+		}
+		doc, ok := ix.docs[h.id]
+		if !ok {
+			continue
+		}
+		f.Categories[doc.category]++
+		f.Brands[doc.brand]++
+		if i := priceBucket(doc.price); i >= 0 {
+			f.Prices[i].Count++
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// countingStore counts product loads, which on a DiskStore are disk
+// reads.
+type countingStore struct {
+	store.Store
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(id int) (model.Product, bool) {
+	s.gets.Add(1)
+	return s.Store.Get(id)
+}
+
+func facetCatalog() *store.ProductStore {
+	s := store.New()
+	categories := []string{"Tools", "Toys", "Garden"}
+	brands := []string{"Acme", "Bolt"}
+	for id := 1; id <= 300; id++ {
+		s.Put(model.Product{
+			ID:       id,
+			Name:     fmt.Sprintf("Widget %d", id),
+			Category: categories[id%3],
+			Brand:    brands[id%2],
+			Price:    float64(id * 10),
+		})
+	}
+	return s
+}
+
+func TestFacetsCountEveryMatch(t *testing.T) {
+	s := &countingStore{Store: facetCatalog()}
+	e := New(s, StrategyIndex)
+	res, err := e.Execute(context.Background(), Request{Query: "widget", Limit: 5, Facets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := res.Facets
+	if want := map[string]int{"Tools": 100, "Toys": 100, "Garden": 100}; !maps.Equal(f.Categories, want) {
+		t.Errorf("categories = %v, want %v", f.Categories, want)
+	}
+	if want := map[string]int{"Acme": 150, "Bolt": 150}; !maps.Equal(f.Brands, want) {
+		t.Errorf("brands = %v, want %v", f.Brands, want)
+	}
+	total := 0
+	for _, b := range f.Prices {
+		total += b.Count
+	}
+	// Prices run 10 to 3000: one product per ten dollars.
+	if total != 300 || f.Prices[1].Count != 2 || f.Prices[len(f.Prices)-1].Count != 51 {
+		t.Errorf("prices = %+v", f.Prices)
+	}
+	if n := s.gets.Load(); n > int64(len(res.Products)) {
+		t.Errorf("%d products loaded for %d returned; facets must not load hits", n, len(res.Products))
+	}
+}
+
+func TestFacetsFollowWrites(t *testing.T) {
+	s := facetCatalog()
+	e := New(s, StrategyIndex)
+	s.Put(model.Product{ID: 3, Name: "Widget 3", Category: "Kitchen", Brand: "Bolt", Price: 5})
+	s.Delete(9)
+	res, err := e.Execute(context.Background(), Request{Query: "widget", Facets: true, Filters: Filters{Brand: "bolt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"Tools": 48, "Toys": 50, "Garden": 50, "Kitchen": 1}; !maps.Equal(res.Facets.Categories, want) {
+		t.Errorf("categories = %v, want %v", res.Facets.Categories, want)
+	}
+	if res.Facets.Prices[0].Count != 1 {
+		t.Errorf("updated price not counted: %+v", res.Facets.Prices[0])
+	}
+}
+
+// TestFacetsConcurrentWrites computes facets while the catalog is
+// written; run it with -race.
+func TestFacetsConcurrentWrites(t *testing.T) {
+	s := facetCatalog()
+	e := New(s, StrategyIndex)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 2000 {
+			id := 1 + i%300
+			if i%2 == 0 {
+				s.Delete(id)
+			} else {
+				s.Put(model.Product{ID: id, Name: "Widget", Category: "Toys", Brand: "Acme", Price: 20})
+			}
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for range 200 {
+				res, err := e.Execute(context.Background(), Request{Query: "widget", Facets: true})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n := 0
+				for _, c := range res.Facets.Brands {
+					n += c
+				}
+				if n > res.TotalFound {
+					t.Errorf("facets count %d products of %d found", n, res.TotalFound)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// Filters restrict a search to products with matching attributes.
+// Zero fields don't filter; Category and Brand compare
+// case-insensitively and the price bounds are inclusive.
+type Filters struct {
+	Category string
+	Brand    string
+	MinPrice *float64
+	MaxPrice *float64
+}
+
+// empty reports whether no filter is set.
+func (f Filters) empty() bool {
+	return f.Category == "" && f.Brand == "" && f.MinPrice == nil && f.MaxPrice == nil
+}
+
+// validate rejects price bounds that can never match.
+func (f Filters) validate() error {
+	lo, hi := f.priceRange()
+	switch {
+	case math.IsNaN(lo) || math.IsNaN(hi):
+		return fmt.Errorf("%w: price bounds must be numbers", ErrInvalidRequest)
+	case lo > hi:
+		return fmt.Errorf("%w: min_price must not exceed max_price", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// priceRange returns the price bounds with unset ones left open.
+func (f Filters) priceRange() (float64, float64) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if f.MinPrice != nil {
+		lo = *f.MinPrice
+	}
+	if f.MaxPrice != nil {
+		hi = *f.MaxPrice
+	}
+	return lo, hi
+}
+
+// match reports whether p satisfies every filter.
+func (f Filters) match(p model.Product) bool {
+	lo, hi := f.priceRange()
+	return (f.Category == "" || strings.EqualFold(p.Category, f.Category)) &&
+		(f.Brand == "" || strings.EqualFold(p.Brand, f.Brand)) &&
+		p.Price >= lo && p.Price <= hi
+}
+
+// ids resolves the filters against the store's secondary indexes and
+// returns the sorted IDs of products satisfying all of them. It must
+// only be called on non-empty filters.
+func (f Filters) ids(s store.Store) []int {
+	var sets [][]int
+	if f.Category != "" {
+		sets = append(sets, s.ByCategory(f.Category))
+	}
+	if f.Brand != "" {
+		sets = append(sets, s.ByBrand(f.Brand))
+	}
+	if f.MinPrice != nil || f.MaxPrice != nil {
+		sets = append(sets, s.ByPriceRange(f.priceRange()))
+	}
+
+	ids := sets[0]
+	for _, other := range sets[1:] {
+		ids = intersect(ids, other)
+	}
+	return ids
+}
+
+// key returns a canonical encoding of the filters for fingerprinting.
+func (f Filters) key() string {
+	lo, hi := f.priceRange()
+	return fmt.Sprintf("%s\x00%s\x00%g\x00%g", strings.ToLower(f.Category), strings.ToLower(f.Brand), lo, hi)
+}
+
+// intersect returns the IDs present in both sorted slices. It reuses
+// the backing array of a.
+func intersect(a, b []int) []int {
+	out := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
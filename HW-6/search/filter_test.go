@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+func filterCatalog() *store.ProductStore {
+	s := store.New()
+	for _, p := range []model.Product{
+		{ID: 1, Name: "Trail Shoe", Category: "Footwear", Brand: "Acme", Price: 80},
+		{ID: 2, Name: "Road Shoe", Category: "Footwear", Brand: "Bolt", Price: 120},
+		{ID: 3, Name: "Shoe Rack", Category: "Home", Brand: "Acme", Price: 45},
+		{ID: 4, Name: "Sock Pack", Category: "Footwear", Brand: "Acme", Price: 12},
+	} {
+		s.Put(p)
+	}
+	return s
+}
+
+func TestFilters(t *testing.T) {
+	e := New(filterCatalog(), StrategyIndex)
+	price := func(p float64) *float64 { return &p }
+	tests := []struct {
+		query   string
+		filters Filters
+		want    []int
+	}{
+		{"", Filters{Category: "footwear"}, []int{1, 2, 4}},
+		{"", Filters{Brand: "ACME", Category: "Footwear"}, []int{1, 4}},
+		{"", Filters{MinPrice: price(45), MaxPrice: price(80)}, []int{1, 3}},
+		{"", Filters{Brand: "acme", MaxPrice: price(50)}, []int{3, 4}},
+		{"shoe", Filters{Brand: "acme"}, []int{1, 3}},
+		{"shoe", Filters{Category: "footwear", MinPrice: price(100)}, []int{2}},
+		{"shoe", Filters{Brand: "nobody"}, nil},
+	}
+	for _, tt := range tests {
+		for _, strategy := range []Strategy{StrategyIndex, StrategyScan} {
+			res, err := e.Execute(context.Background(), Request{Query: tt.query, Filters: tt.filters, Strategy: strategy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, h := range res.Products {
+				got = append(got, h.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) || res.TotalFound != len(tt.want) {
+				t.Errorf("%s %q %+v: got %v of %d, want %v", strategy, tt.query, tt.filters, got, res.TotalFound, tt.want)
+			}
+		}
+	}
+}
+
+func TestFiltersRejectEmptyPriceRange(t *testing.T) {
+	e := New(filterCatalog(), StrategyIndex)
+	lo, hi := 50.0, 10.0
+	_, err := e.Execute(context.Background(), Request{Filters: Filters{MinPrice: &lo, MaxPrice: &hi}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("min_price above max_price: %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	if got := intersect([]int{1, 3, 5, 7, 9}, []int{2, 3, 4, 9, 10}); !slices.Equal(got, []int{3, 9}) {
+		t.Errorf("intersect = %v, want [3 9]", got)
+	}
+	if got := intersect([]int{1, 2}, nil); len(got) != 0 {
+		t.Errorf("intersect with nothing = %v", got)
+	}
+}
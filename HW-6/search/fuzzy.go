@@ -0,0 +1,111 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Correction records a query term that was replaced by the closest
+// term in the index vocabulary.
+type Correction struct {
+	Term      string `json:"term"`
+	Corrected string `json:"corrected"`
+}
+
+// maxEdits returns how many edits a term of the given length may be
+// corrected by. Short terms are left alone: one edit away from a
+// three-letter word is almost any other three-letter word.
+func maxEdits(length int) int {
+	switch {
+	case length < 3:
+		return 0
+	case length < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// correct replaces every query token missing from the index with its
+// nearest vocabulary term within maxEdits, in place, and returns the
+// corrections made. A corrected term's text becomes its tokens.
+func (e *Engine) correct(query node) []Correction {
+	var corrections []Correction
+	visitTerms(query, false, func(t *termNode, _ bool) {
+		for i, tok := range t.tokens {
+			if fixed, ok := e.index.Correct(tok, maxEdits(utf8.RuneCountInString(tok))); ok {
+				corrections = append(corrections, Correction{Term: tok, Corrected: fixed})
+				t.tokens[i] = fixed
+				t.text = strings.Join(t.tokens, " ")
+			}
+		}
+	})
+	return corrections
+}
+
+// Correct returns the indexed term closest to term within maxEdits, or
+// false if term is itself indexed or nothing is close enough. Ties go
+// to the term found in the most products.
+func (ix *Index) Correct(term string, maxEdits int) (string, bool) {
+	if maxEdits == 0 {
+		return "", false
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if _, ok := ix.postings[term]; ok {
+		return "", false
+	}
+
+	src := []rune(term)
+	best, bestDist, bestDF := "", maxEdits, 0
+	for cand, list := range ix.postings {
+		n := utf8.RuneCountInString(cand)
+		if n < len(src)-maxEdits || n > len(src)+maxEdits {
+			continue
+		}
+		d := editDistance(src, []rune(cand), bestDist)
+		if d > bestDist {
+			continue
+		}
+		if best == "" || d < bestDist || len(list) > bestDF || len(list) == bestDF && cand < best {
+			best, bestDist, bestDF = cand, d, len(list)
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the optimal string alignment distance between a
+// and b: Levenshtein distance where swapping two adjacent runes also
+// counts as one edit, since transpositions are the most common typo.
+// Once every alignment exceeds limit it stops and returns limit+1.
+func editDistance(a, b []rune, limit int) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
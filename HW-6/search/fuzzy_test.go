@@ -0,0 +1,60 @@
+package search
+
+import (
+	"testing"
+
+	"product-search/model"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"laptop", "laptop", 2, 0},
+		{"laptp", "laptop", 2, 1},
+		{"lpatop", "laptop", 2, 1}, // transposition
+		{"latpop", "laptop", 2, 1},
+		{"kitten", "sitting", 5, 3},
+		{"", "abc", 5, 3},
+		{"lxxxop", "laptop", 5, 3},
+		{"lxxxop", "laptop", 2, 3}, // stops past the limit
+		{"abcd", "wxyz", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	ix := NewIndex()
+	for i, name := range []string{"laptop", "abxy", "lamp", "lamp", "camp", "ramp", "ramp", "ramp"} {
+		ix.ProductPut(model.Product{ID: i + 1, Name: name})
+	}
+	tests := []struct {
+		term     string
+		maxEdits int
+		want     string
+	}{
+		{"laptop", 2, ""}, // already indexed
+		{"laptp", 1, "laptop"},
+		{"lpatop", 1, "laptop"},
+		{"lxxtop", 2, "laptop"},
+		{"lxxxop", 2, ""}, // 3 edits away
+		{"abcd", 1, ""},   // 2 edits away
+		{"abcy", 1, "abxy"},
+		{"xamp", 1, "ramp"}, // ramp is in the most products
+		{"damp", 1, "ramp"},
+		{"lamps", 1, "lamp"}, // fewer edits beat more products
+		{"laptp", 0, ""},
+	}
+	for _, tt := range tests {
+		got, ok := ix.Correct(tt.term, tt.maxEdits)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Correct(%q, %d) = %q, %v; want %q", tt.term, tt.maxEdits, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"product-search/model"
+)
+
+const (
+	// snippetLen is roughly how many bytes of a description a
+	// highlight fragment shows.
+	snippetLen = 160
+
+	// snippetLead is how much context a snippet keeps before the first
+	// match.
+	snippetLead = 40
+)
+
+// Fragment shows where the query matched one field of a hit. Text is
+// the field, HTML-escaped, with every matched token wrapped in
+// <em></em>; for descriptions it is a snippet around the first match,
+// marked with "…" where cut. Matches holds the [start, end) byte
+// offsets of the matched tokens in the full, unescaped field value.
+type Fragment struct {
+	Text    string   `json:"text"`
+	Matches [][2]int `json:"matches"`
+}
+
+// highlighter marks the tokens a query requires, per field. Negated
+// terms are not highlighted; they never occur in a hit.
+type highlighter struct {
+	terms [numFields]map[string]bool
+}
+
+// newHighlighter collects the required tokens of query by the field
+// they are scoped to. Fuzzy corrections must already be applied.
+func newHighlighter(query node) *highlighter {
+	hl := &highlighter{}
+	for f := range hl.terms {
+		hl.terms[f] = make(map[string]bool)
+	}
+	visitTerms(query, false, func(t *termNode, negated bool) {
+		if negated {
+			return
+		}
+		for f := range hl.terms {
+			if t.field == anyField || t.field == field(f) {
+				for _, tok := range t.tokens {
+					hl.terms[f][tok] = true
+				}
+			}
+		}
+	})
+	return hl
+}
+
+// highlight returns a fragment for every field of p the query matched,
+// keyed by field name, or nil if none did.
+func (hl *highlighter) highlight(p model.Product) map[string]Fragment {
+	var out map[string]Fragment
+	for f, text := range productFields(p) {
+		var matches [][2]int
+		for _, s := range tokenSpans(text) {
+			if hl.terms[f][strings.ToLower(text[s[0]:s[1]])] {
+				matches = append(matches, s)
+			}
+		}
+		if matches == nil {
+			continue
+		}
+		start, end := 0, len(text)
+		if field(f) == fieldDescription {
+			start, end = snippet(text, matches[0][0])
+		}
+		if out == nil {
+			out = make(map[string]Fragment)
+		}
+		out[fieldNames[f]] = Fragment{Text: markup(text, start, end, matches), Matches: matches}
+	}
+	return out
+}
+
+// tokenSpans returns the byte offsets of the tokens tokenize would
+// produce from text.
+func tokenSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		inToken := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inToken && start < 0:
+			start = i
+		case !inToken && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// snippet picks the [start, end) window of text shown around the match
+// at offset at, moved to word boundaries.
+func snippet(text string, at int) (int, int) {
+	if len(text) <= snippetLen {
+		return 0, len(text)
+	}
+	start := max(0, at-snippetLead)
+	if start > 0 {
+		if i := strings.IndexByte(text[start:at], ' '); i >= 0 {
+			start += i + 1
+		} else {
+			start = at
+		}
+	}
+	end := min(len(text), start+snippetLen)
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[at:end], ' '); i > 0 {
+			end = at + i
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+	}
+	return start, end
+}
+
+// markup returns text[start:end] escaped for HTML, with the matches
+// that fall inside it wrapped in <em></em>.
+func markup(text string, start, end int, matches [][2]int) string {
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</em>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
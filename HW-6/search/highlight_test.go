@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// highlightFor highlights p for query.
+func highlightFor(t *testing.T, query string, p model.Product) map[string]Fragment {
+	t.Helper()
+	q, err := parseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newHighlighter(q).highlight(p)
+}
+
+func TestHighlight(t *testing.T) {
+	p := model.Product{
+		ID:          1,
+		Name:        "Widget <b>Pro</b> & Co",
+		Category:    "Tools",
+		Brand:       "Widget Works",
+		Description: "A pro widget.",
+	}
+	tests := []struct {
+		query string
+		want  map[string]Fragment
+	}{
+		{"widget pro", map[string]Fragment{
+			"name": {
+				Text:    "<em>Widget</em> &lt;b&gt;<em>Pro</em>&lt;/b&gt; &amp; Co",
+				Matches: [][2]int{{0, 6}, {10, 13}},
+			},
+			"brand":       {Text: "<em>Widget</em> Works", Matches: [][2]int{{0, 6}}},
+			"description": {Text: "A <em>pro</em> <em>widget</em>.", Matches: [][2]int{{2, 5}, {6, 12}}},
+		}},
+		{"name:widget", map[string]Fragment{
+			"name": {Text: "<em>Widget</em> &lt;b&gt;Pro&lt;/b&gt; &amp; Co", Matches: [][2]int{{0, 6}}},
+		}},
+		// Negated terms are not marked, even where they occur.
+		{"tools OR -pro", map[string]Fragment{
+			"category": {Text: "<em>Tools</em>", Matches: [][2]int{{0, 5}}},
+		}},
+		{"gadget", nil},
+	}
+	for _, tt := range tests {
+		if got := highlightFor(t, tt.query, p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("highlight(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestHighlightSnippet checks that a long description is cut to a
+// window around its first match, at word boundaries.
+func TestHighlightSnippet(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum ", 30)
+	desc := filler + "the sturdy widget lasts " + filler
+	p := model.Product{Name: "Thing", Description: desc}
+	frag := highlightFor(t, "widget", p)["description"]
+
+	at := strings.Index(desc, "widget")
+	if want := [][2]int{{at, at + len("widget")}}; !reflect.DeepEqual(frag.Matches, want) {
+		t.Fatalf("matches %v, want %v", frag.Matches, want)
+	}
+	if !strings.HasPrefix(frag.Text, "…") || !strings.HasSuffix(frag.Text, "…") {
+		t.Fatalf("snippet %q is not marked as cut at both ends", frag.Text)
+	}
+	text := strings.Trim(frag.Text, "…")
+	if !strings.Contains(text, "the sturdy <em>widget</em> lasts") {
+		t.Fatalf("snippet %q lacks the match in context", frag.Text)
+	}
+	words := strings.Fields(strings.ReplaceAll(text, "<em>widget</em>", "widget"))
+	for _, w := range words {
+		if !strings.Contains(desc, " "+w+" ") {
+			t.Fatalf("snippet %q cuts a word: %q", frag.Text, w)
+		}
+	}
+	if n := len(strings.Join(words, " ")); n > snippetLen {
+		t.Fatalf("snippet is %d bytes, longer than %d", n, snippetLen)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("abcd ", 60) // 300 bytes
+	tests := []struct {
+		name       string
+		text       string
+		at         int
+		start, end int
+	}{
+		{"short text is whole", "a short description", 8, 0, 19},
+		{"match near the start", long, 10, 0, 159},
+		{"match in the middle", long, 150, 115, 274},
+		{"match near the end", long, 290, 255, 300},
+		{"no space before the match", strings.Repeat("x", 100) + " " + long, 60, 60, 215},
+	}
+	for _, tt := range tests {
+		start, end := snippet(tt.text, tt.at)
+		if start != tt.start || end != tt.end {
+			t.Errorf("%s: snippet = [%d, %d), want [%d, %d)", tt.name, start, end, tt.start, tt.end)
+		}
+	}
+
+	// A cut never splits a multi-byte character.
+	text := strings.Repeat("é", 200)
+	if _, end := snippet(text, 0); !strings.HasPrefix(text, text[:end]) || end%2 != 0 {
+		t.Errorf("snippet of multi-byte text ends at byte %d", end)
+	}
+}
+
+// TestSearchHighlights checks that only requested results carry
+// highlights, and that they mark the corrected term of a fuzzy query.
+func TestSearchHighlights(t *testing.T) {
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Laptop Stand", Category: "Office"})
+	e := New(s, StrategyIndex)
+	ctx := context.Background()
+
+	res, err := e.Execute(ctx, Request{Query: "laptop"})
+	if err != nil || len(res.Products) != 1 || res.Products[0].Highlight != nil {
+		t.Fatalf("search without highlighting: %+v, %v", res, err)
+	}
+	res, err = e.Execute(ctx, Request{Query: "laptpo", Fuzzy: true, Highlight: true})
+	if err != nil || len(res.Products) != 1 {
+		t.Fatalf("fuzzy search: %+v, %v", res, err)
+	}
+	want := map[string]Fragment{"name": {Text: "<em>Laptop</em> Stand", Matches: [][2]int{{0, 6}}}}
+	if got := res.Products[0].Highlight; !reflect.DeepEqual(got, want) {
+		t.Fatalf("highlight = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,383 @@
+package search
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"product-search/model"
+)
+
+// field identifies a searchable product field.
+type field int
+
+const (
+	fieldName field = iota
+	fieldCategory
+	fieldBrand
+	fieldDescription
+	numFields
+)
+
+// fieldBoosts weights a term occurrence by the field it appears in,
+// so a hit in the product name outranks one buried in the description.
+var fieldBoosts = [numFields]float64{
+	fieldName:        3.0,
+	fieldCategory:    2.0,
+	fieldBrand:       2.0,
+	fieldDescription: 1.0,
+}
+
+// BM25 parameters: k1 controls term-frequency saturation, b controls
+// how strongly field length normalizes the frequency.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// posting records how often a token occurs in each field of a product.
+type posting struct {
+	id int
+	tf [numFields]uint16
+}
+
+// docInfo is what the index remembers about each product: its field
+// lengths (for BM25 normalization), tokens (for re-indexing) and the
+// attributes facets count, so they never load products.
+type docInfo struct {
+	lens     [numFields]int
+	tokens   []string
+	category string
+	brand    string
+	price    float64
+}
+
+// Index is an inverted index from normalized tokens to product IDs.
+// It implements store.Observer, so subscribing it to a store.Store
+// keeps it current as products are written and deleted. Safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string][]posting // token → postings sorted by product ID
+	docs     map[int]docInfo
+	totalLen [numFields]int    // summed field lengths, for averages
+	values   map[string]string // interned categories and brands
+}
+
+// scored is a product ID paired with its relevance score.
+type scored struct {
+	id    int
+	score float64
+}
+
+// NewIndex creates an empty Index.
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string][]posting),
+		docs:     make(map[int]docInfo),
+		values:   make(map[string]string),
+	}
+}
+
+// ProductPut indexes the searchable fields of a product, replacing any
+// postings left by a previous version with the same ID.
+func (ix *Index) ProductPut(p model.Product) {
+	doc := docInfo{price: p.Price}
+	freqs := make(map[string]*[numFields]uint16)
+	for f, text := range productFields(p) {
+		toks := tokenize(text)
+		doc.lens[f] = len(toks)
+		for _, tok := range toks {
+			tf, ok := freqs[tok]
+			if !ok {
+				tf = new([numFields]uint16)
+				freqs[tok] = tf
+				doc.tokens = append(doc.tokens, tok)
+			}
+			if tf[f] < math.MaxUint16 {
+				tf[f]++
+			}
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(p.ID)
+	doc.category, doc.brand = ix.intern(p.Category), ix.intern(p.Brand)
+	for _, tok := range doc.tokens {
+		ix.postings[tok] = insertPosting(ix.postings[tok], posting{id: p.ID, tf: *freqs[tok]})
+	}
+	for f := range doc.lens {
+		ix.totalLen[f] += doc.lens[f]
+	}
+	ix.docs[p.ID] = doc
+}
+
+// ProductDeleted drops every posting for the product.
+func (ix *Index) ProductDeleted(p model.Product) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(p.ID)
+}
+
+// remove drops every posting for id. Callers must hold ix.mu.
+func (ix *Index) remove(id int) {
+	old, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, tok := range old.tokens {
+		ix.postings[tok] = removePosting(ix.postings[tok], id)
+		if len(ix.postings[tok]) == 0 {
+			delete(ix.postings, tok)
+		}
+	}
+	for f := range old.lens {
+		ix.totalLen[f] -= old.lens[f]
+	}
+	delete(ix.docs, id)
+}
+
+// intern returns the shared copy of s, so products of one category or
+// brand hold a single string between them. Callers must hold ix.mu
+// exclusively.
+func (ix *Index) intern(s string) string {
+	if v, ok := ix.values[s]; ok {
+		return v
+	}
+	ix.values[s] = s
+	return s
+}
+
+// checkEvery is how many postings or hits the index and engine process
+// between checks of their context.
+const checkEvery = 1024
+
+// Lookup returns every product containing all of terms, scored with
+// BM25F. An empty term list matches nothing. If ctx is done first, it
+// returns the matches found so far.
+func (ix *Index) Lookup(ctx context.Context, terms []string) []scored {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	lists := make([][]posting, 0, len(terms))
+	for _, t := range terms {
+		list, ok := ix.postings[t]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, list)
+	}
+
+	// Drive the intersection from the rarest term and probe the
+	// others, so the work is bounded by the shortest posting list.
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	avg := ix.avgLens()
+	var hits []scored
+next:
+	for i, p := range lists[0] {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			break
+		}
+		score := ix.bm25(p, len(lists[0]), avg)
+		for _, list := range lists[1:] {
+			q, ok := findPosting(list, p.id)
+			if !ok {
+				continue next
+			}
+			score += ix.bm25(q, len(list), avg)
+		}
+		hits = append(hits, scored{id: p.id, score: score})
+	}
+	return hits
+}
+
+// Score returns the BM25F relevance of product id for terms. Terms the
+// product does not contain contribute nothing.
+func (ix *Index) Score(id int, terms []string) float64 {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	avg := ix.avgLens()
+	score := 0.0
+	for _, t := range terms {
+		list := ix.postings[t]
+		if p, ok := findPosting(list, id); ok {
+			score += ix.bm25(p, len(list), avg)
+		}
+	}
+	return score
+}
+
+// ScoreAll returns ids paired with their BM25F relevance for terms. If
+// ctx is done first, it returns only the ones scored so far.
+func (ix *Index) ScoreAll(ctx context.Context, ids []int, terms []string) []scored {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	avg := ix.avgLens()
+	hits := make([]scored, len(ids))
+	for i, id := range ids {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			return hits[:i]
+		}
+		hits[i].id = id
+		for _, t := range terms {
+			list := ix.postings[t]
+			if p, ok := findPosting(list, id); ok {
+				hits[i].score += ix.bm25(p, len(list), avg)
+			}
+		}
+	}
+	return hits
+}
+
+// contains reports whether product id is indexed.
+func (ix *Index) contains(id int) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.docs[id]
+	return ok
+}
+
+// ids returns the sorted IDs of products containing tok in field f, or
+// in any field if f is anyField.
+func (ix *Index) ids(tok string, f field) []int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	list := ix.postings[tok]
+	ids := make([]int, 0, len(list))
+	for _, p := range list {
+		if f == anyField || p.tf[f] > 0 {
+			ids = append(ids, p.id)
+		}
+	}
+	return ids
+}
+
+// allIDs returns the sorted IDs of every indexed product.
+func (ix *Index) allIDs() []int {
+	ix.mu.RLock()
+	ids := make([]int, 0, len(ix.docs))
+	for id := range ix.docs {
+		ids = append(ids, id)
+	}
+	ix.mu.RUnlock()
+	slices.Sort(ids)
+	return ids
+}
+
+// bm25 scores one posting using BM25F: per-field frequencies are
+// length-normalized and boosted, summed, then saturated once.
+// Callers must hold ix.mu.
+func (ix *Index) bm25(p posting, df int, avg [numFields]float64) float64 {
+	doc := ix.docs[p.id]
+	tf := 0.0
+	for f := range numFields {
+		if p.tf[f] == 0 || avg[f] == 0 {
+			continue
+		}
+		norm := 1 - bm25B + bm25B*float64(doc.lens[f])/avg[f]
+		tf += fieldBoosts[f] * float64(p.tf[f]) / norm
+	}
+	n := float64(len(ix.docs))
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1)
+}
+
+// avgLens returns the mean token count of each field. Callers must
+// hold ix.mu.
+func (ix *Index) avgLens() [numFields]float64 {
+	var avg [numFields]float64
+	if n := len(ix.docs); n > 0 {
+		for f := range avg {
+			avg[f] = float64(ix.totalLen[f]) / float64(n)
+		}
+	}
+	return avg
+}
+
+// productFields returns the searchable text of p indexed by field.
+func productFields(p model.Product) [numFields]string {
+	return [numFields]string{
+		fieldName:        p.Name,
+		fieldCategory:    p.Category,
+		fieldBrand:       p.Brand,
+		fieldDescription: p.Description,
+	}
+}
+
+// tokenize lower-cases the given fields and splits them on anything
+// that is not a letter or digit.
+func tokenize(fields ...string) []string {
+	var tokens []string
+	for _, f := range fields {
+		tokens = append(tokens, strings.FieldsFunc(strings.ToLower(f), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return tokens
+}
+
+// distinct returns tokens with duplicates removed, preserving order.
+func distinct(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	out := tokens[:0:0]
+	for _, t := range tokens {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// insertPosting adds p to a list sorted by product ID. Products are
+// usually Put in ascending ID order, so appending is the common case.
+func insertPosting(list []posting, p posting) []posting {
+	n := len(list)
+	if n == 0 || list[n-1].id < p.id {
+		return append(list, p)
+	}
+	i := sort.Search(n, func(i int) bool { return list[i].id >= p.id })
+	if list[i].id == p.id {
+		list[i] = p
+		return list
+	}
+	list = append(list, posting{})
+	copy(list[i+1:], list[i:])
+	list[i] = p
+	return list
+}
+
+// removePosting deletes the posting for id if present.
+func removePosting(list []posting, id int) []posting {
+	if i, ok := searchPosting(list, id); ok {
+		return append(list[:i], list[i+1:]...)
+	}
+	return list
+}
+
+// findPosting returns the posting for id if present.
+func findPosting(list []posting, id int) (posting, bool) {
+	if i, ok := searchPosting(list, id); ok {
+		return list[i], true
+	}
+	return posting{}, false
+}
+
+// searchPosting binary-searches a sorted posting list for id.
+func searchPosting(list []posting, id int) (int, bool) {
+	i := sort.Search(len(list), func(i int) bool { return list[i].id >= id })
+	return i, i < len(list) && list[i].id == id
+}
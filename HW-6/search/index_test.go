@@ -0,0 +1,157 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// hitIDs returns the sorted IDs of hits.
+func hitIDs(hits []scored) []int {
+	ids := make([]int, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestIndexLookup(t *testing.T) {
+	ix := NewIndex()
+	ix.ProductPut(model.Product{ID: 1, Name: "Red Widget", Category: "Tools", Brand: "Acme"})
+	ix.ProductPut(model.Product{ID: 2, Name: "Blue Widget", Category: "Tools", Brand: "Bolt"})
+	ix.ProductPut(model.Product{ID: 3, Name: "Red Gadget", Category: "Toys", Description: "Not a widget-maker"})
+	ctx := context.Background()
+
+	tests := []struct {
+		terms []string
+		want  []int
+	}{
+		{[]string{"widget"}, []int{1, 2, 3}},
+		{[]string{"red", "widget"}, []int{1, 3}},
+		{[]string{"acme"}, []int{1}},
+		{[]string{"maker"}, []int{3}},
+		{[]string{"red", "missing"}, nil},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if got := hitIDs(ix.Lookup(ctx, tt.terms)); !slices.Equal(got, tt.want) {
+			t.Errorf("Lookup(%q) = %v, want %v", tt.terms, got, tt.want)
+		}
+	}
+
+	// Re-putting a product replaces its postings; deleting drops them.
+	ix.ProductPut(model.Product{ID: 1, Name: "Green Sprocket", Category: "Tools"})
+	ix.ProductDeleted(model.Product{ID: 2})
+	if got := hitIDs(ix.Lookup(ctx, []string{"widget"})); !slices.Equal(got, []int{3}) {
+		t.Errorf("widget after writes = %v, want [3]", got)
+	}
+	if got := hitIDs(ix.Lookup(ctx, []string{"sprocket"})); !slices.Equal(got, []int{1}) {
+		t.Errorf("sprocket after writes = %v, want [1]", got)
+	}
+	if _, ok := ix.postings["bolt"]; ok {
+		t.Error("the deleted product's tokens are still indexed")
+	}
+}
+
+// TestIndexStrategyReachesWholeCatalog checks that the index finds
+// products past the MaxCheck the scan strategy stops at.
+func TestIndexStrategyReachesWholeCatalog(t *testing.T) {
+	s := store.New()
+	for id := 1; id <= 3*MaxCheck; id++ {
+		s.Put(model.Product{ID: id, Name: fmt.Sprintf("Widget %d", id), Category: "tools"})
+	}
+	s.Put(model.Product{ID: 3*MaxCheck + 1, Name: "Rare Gadget", Category: "tools"})
+	e := New(s, StrategyIndex)
+	ctx := context.Background()
+
+	res, err := e.Execute(ctx, Request{Query: "gadget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TotalFound != 1 || res.Products[0].ID != 3*MaxCheck+1 || res.Strategy != StrategyIndex {
+		t.Fatalf("index search = %+v", res)
+	}
+	if res.Checked != 1 {
+		t.Errorf("index search checked %d products, want only the match", res.Checked)
+	}
+
+	res, err = e.Execute(ctx, Request{Query: "widget"})
+	if err != nil || res.TotalFound != 3*MaxCheck {
+		t.Fatalf("index search for widget found %d, %v; want %d", res.TotalFound, err, 3*MaxCheck)
+	}
+
+	res, err = e.Execute(ctx, Request{Query: "gadget", Strategy: StrategyScan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TotalFound != 0 || res.Checked != MaxCheck {
+		t.Fatalf("scan found %d after checking %d, want 0 after %d", res.TotalFound, res.Checked, MaxCheck)
+	}
+}
+
+// TestBM25FRanking checks the ranking signals against each other on
+// products that differ in one respect each.
+func TestBM25FRanking(t *testing.T) {
+	ix := NewIndex()
+	for _, p := range []model.Product{
+		{ID: 1, Name: "Lamp", Description: "A widget for the desk"}, // description hit
+		{ID: 2, Name: "Widget"},  // name hit
+		{ID: 3, Brand: "Widget"}, // brand hit
+		{ID: 4, Name: "Widget Deluxe Extra Large Heavy Duty Steel Edition"}, // long name
+		{ID: 5, Name: "Widget", Description: "widget widget widget"},        // repeated
+		{ID: 6, Name: "Gizmo"},
+		{ID: 7, Name: "Gizmo"},
+	} {
+		ix.ProductPut(p)
+	}
+	score := func(id int, terms ...string) float64 { return ix.Score(id, terms) }
+
+	if !(score(2, "widget") > score(3, "widget") && score(3, "widget") > score(1, "widget")) {
+		t.Errorf("field boosts: name %g, brand %g, description %g; want descending",
+			score(2, "widget"), score(3, "widget"), score(1, "widget"))
+	}
+	if score(2, "widget") <= score(4, "widget") {
+		t.Errorf("a short name scored %g, no more than a long one's %g", score(2, "widget"), score(4, "widget"))
+	}
+	if score(5, "widget") <= score(2, "widget") {
+		t.Errorf("more occurrences scored %g, no more than one's %g", score(5, "widget"), score(2, "widget"))
+	}
+	if score(5, "widget") >= 2*score(2, "widget") {
+		t.Errorf("term frequency did not saturate: %g vs %g", score(5, "widget"), score(2, "widget"))
+	}
+	// gizmo is in two products and widget in five, so gizmo is rarer.
+	if score(6, "gizmo") <= score(2, "widget") {
+		t.Errorf("a rare term scored %g, no more than a common one's %g", score(6, "gizmo"), score(2, "widget"))
+	}
+	if score(6, "widget") != 0 {
+		t.Errorf("a product without the term scored %g", score(6, "widget"))
+	}
+}
+
+// TestSearchRanksByRelevance checks that hits come back best first
+// whatever their IDs.
+func TestSearchRanksByRelevance(t *testing.T) {
+	s := store.New()
+	s.Put(model.Product{ID: 1, Name: "Desk Lamp", Category: "Home", Description: "Pairs with any chair"})
+	s.Put(model.Product{ID: 2, Name: "Office Chair", Category: "Furniture"})
+	s.Put(model.Product{ID: 3, Name: "Chair Mat", Category: "Chair Accessories"})
+	res, err := New(s, StrategyIndex).Execute(context.Background(), Request{Query: "chair"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for i, h := range res.Products {
+		got = append(got, h.ID)
+		if i > 0 && h.Score > res.Products[i-1].Score {
+			t.Errorf("hit %d scored %g, above hit %d", h.ID, h.Score, res.Products[i-1].ID)
+		}
+	}
+	if !slices.Equal(got, []int{3, 2, 1}) {
+		t.Fatalf("ranked %v, want [3 2 1]", got)
+	}
+}
@@ -0,0 +1,340 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Query syntax. A query is parsed into an AST of nodes (see eval.go)
+// by a recursive-descent parser over this grammar:
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "-" | "NOT" ) unary | primary
+//	primary = "(" or ")" | field ":" value | word | "\"" phrase "\""
+//
+// Juxtaposed terms are ANDed, and AND binds tighter than OR. Operators
+// must be upper case; "and", "or" and "not" are ordinary words. A word
+// or phrase matches products containing its tokens consecutively in
+// one field. The fields are name, category, brand and description,
+// which take a word or phrase, and price, which takes a bound such as
+// <500, >=10 or =25, or an inclusive range such as 10..50. Words with
+// no letters or digits, like "&", are ignored.
+//
+// For example:
+//
+//	brand:apple AND (laptop OR tablet) -refurbished price:<500
+
+// SyntaxError reports a malformed query. Pos is the byte offset in the
+// query at which the problem was found. It wraps ErrInvalidRequest.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v: %s at position %d", ErrInvalidRequest, e.Msg, e.Pos)
+}
+
+func (e *SyntaxError) Unwrap() error { return ErrInvalidRequest }
+
+// tokenKind classifies a lexical token of the query language.
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // bare word
+	tokPhrase           // quoted phrase, without the quotes
+	tokField            // field name; the ':' is consumed
+	tokLParen
+	tokRParen
+	tokMinus
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits a query into tokens, ending with tokEOF.
+func lex(q string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(q); {
+		switch c := q[i]; {
+		case isSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated phrase"}
+			}
+			toks = append(toks, token{kind: tokPhrase, text: q[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c == '-' && i+1 < len(q) && !isSpace(q[i+1]) && q[i+1] != ')':
+			toks = append(toks, token{kind: tokMinus, text: "-", pos: i})
+			i++
+		default:
+			start := i
+			for i < len(q) && !isSpace(q[i]) && !strings.ContainsRune("()\":", rune(q[i])) {
+				i++
+			}
+			if i < len(q) && q[i] == ':' {
+				if i == start {
+					return nil, &SyntaxError{Pos: i, Msg: "missing field name before ':'"}
+				}
+				toks = append(toks, token{kind: tokField, text: q[start:i], pos: start})
+				i++
+				continue
+			}
+			t := token{kind: tokWord, text: q[start:i], pos: start}
+			switch t.text {
+			case "AND":
+				t.kind = tokAnd
+			case "OR":
+				t.kind = tokOr
+			case "NOT":
+				t.kind = tokNot
+			}
+			toks = append(toks, t)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(q)}), nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// parseQuery parses q into an AST. A blank query yields a nil node,
+// which places no constraint; a query made only of ignored words
+// yields one that matches nothing.
+func parseQuery(q string) (node, error) {
+	if strings.TrimSpace(q) == "" {
+		return nil, nil
+	}
+	toks, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, unexpected(t)
+	}
+	if n == nil {
+		return noneNode{}, nil
+	}
+	return n, nil
+}
+
+// parser is a recursive-descent parser over lexed tokens. Its parse
+// methods return a nil node for a construct that places no constraint,
+// such as an ignored word, so callers drop it.
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	var alts orNode
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			alts = append(alts, n)
+		}
+		if p.peek().kind != tokOr {
+			break
+		}
+		p.next()
+	}
+	switch len(alts) {
+	case 0:
+		return nil, nil
+	case 1:
+		return alts[0], nil
+	}
+	return alts, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var all andNode
+	for first := true; ; first = false {
+		if !first {
+			switch p.peek().kind {
+			case tokAnd:
+				p.next()
+			case tokWord, tokPhrase, tokField, tokLParen, tokMinus, tokNot:
+			default:
+				switch len(all) {
+				case 0:
+					return nil, nil
+				case 1:
+					return all[0], nil
+				}
+				return all, nil
+			}
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			all = append(all, n)
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if k := p.peek().kind; k == tokMinus || k == tokNot {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil || n == nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokWord, tokPhrase:
+		return newTermNode(anyField, t.text), nil
+	case tokField:
+		return p.parseField(t)
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, &SyntaxError{Pos: p.peek().pos, Msg: "empty parentheses"}
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "unclosed '('"}
+		}
+		p.next()
+		return n, nil
+	}
+	return nil, unexpected(t)
+}
+
+// parseField parses the value following field token t, which must
+// come straight after the ':'.
+func (p *parser) parseField(t token) (node, error) {
+	name := strings.ToLower(t.text)
+	f := anyField
+	for i, fname := range fieldNames {
+		if name == fname {
+			f = field(i)
+		}
+	}
+	if f == anyField && name != "price" {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown field %q", t.text)}
+	}
+
+	valuePos := t.pos + len(t.text) + 1
+	v := p.peek()
+	if (v.kind != tokWord && v.kind != tokPhrase) || v.pos != valuePos {
+		return nil, &SyntaxError{Pos: valuePos, Msg: fmt.Sprintf("missing value for field %q", t.text)}
+	}
+	p.next()
+
+	if name == "price" {
+		if v.kind == tokPhrase {
+			return nil, &SyntaxError{Pos: v.pos, Msg: "price takes a number, not a phrase"}
+		}
+		return parsePrice(v)
+	}
+	return newTermNode(f, v.text), nil
+}
+
+// parsePrice parses a price bound or range. Strict bounds are turned
+// into inclusive ones at the neighbouring float.
+func parsePrice(v token) (node, error) {
+	bad := &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("invalid price %q", v.text)}
+	num := func(s string) (float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, bad
+		}
+		return f, nil
+	}
+
+	n := priceNode{lo: math.Inf(-1), hi: math.Inf(1)}
+	s := v.text
+	var err error
+	switch {
+	case strings.HasPrefix(s, "<="):
+		n.hi, err = num(s[2:])
+	case strings.HasPrefix(s, ">="):
+		n.lo, err = num(s[2:])
+	case strings.HasPrefix(s, "<"):
+		n.hi, err = num(s[1:])
+		n.hi = math.Nextafter(n.hi, math.Inf(-1))
+	case strings.HasPrefix(s, ">"):
+		n.lo, err = num(s[1:])
+		n.lo = math.Nextafter(n.lo, math.Inf(1))
+	case strings.Contains(s, ".."):
+		lo, hi, _ := strings.Cut(s, "..")
+		if lo == "" && hi == "" {
+			return nil, bad
+		}
+		if lo != "" {
+			if n.lo, err = num(lo); err != nil {
+				return nil, err
+			}
+		}
+		if hi != "" {
+			n.hi, err = num(hi)
+		}
+	default:
+		n.lo, err = num(strings.TrimPrefix(s, "="))
+		n.hi = n.lo
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n.lo > n.hi {
+		return nil, &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("empty price range %q", v.text)}
+	}
+	return n, nil
+}
+
+// unexpected reports token t where it does not belong.
+func unexpected(t token) error {
+	if t.kind == tokEOF {
+		return &SyntaxError{Pos: t.pos, Msg: "unexpected end of query"}
+	}
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"strings"
+
+	"product-search/model"
+)
+
+// scanText is a product's searchable fields, lower-cased, as the scan
+// strategy matches them.
+type scanText [numFields]string
+
+func newScanText(p model.Product) *scanText {
+	var t scanText
+	for f, text := range productFields(p) {
+		t[f] = strings.ToLower(text)
+	}
+	return &t
+}
+
+// scanMatch reports whether p, whose text is t, matches n the way the
+// scan strategy always has: a term matches if it occurs as written,
+// punctuation included, as a substring of the product's name or
+// category, or of the field it is scoped to. So "phone" finds
+// "iPhone" and "t-shirt" finds "T-Shirt" but not "T Shirt". Separate
+// words are separate terms, each matched anywhere; the operators
+// combine terms as they do for the index.
+func scanMatch(n node, p model.Product, t *scanText) bool {
+	switch n := n.(type) {
+	case *termNode:
+		if n.field != anyField {
+			return strings.Contains(t[n.field], n.text)
+		}
+		return strings.Contains(t[fieldName], n.text) || strings.Contains(t[fieldCategory], n.text)
+	case priceNode:
+		return p.Price >= n.lo && p.Price <= n.hi
+	case andNode:
+		for _, x := range n {
+			if !scanMatch(x, p, t) {
+				return false
+			}
+		}
+		return true
+	case orNode:
+		for _, x := range n {
+			if scanMatch(x, p, t) {
+				return true
+			}
+		}
+		return false
+	case notNode:
+		return !scanMatch(n.x, p, t)
+	}
+	return false
+}
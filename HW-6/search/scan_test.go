@@ -0,0 +1,86 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+func phones() *store.ProductStore {
+	s := store.New()
+	for _, p := range []model.Product{
+		{ID: 1, Name: "iPhone 13", Category: "smartphones", Brand: "Apple", Price: 799},
+		{ID: 2, Name: "Galaxy Smartphone", Category: "smartphones", Brand: "Samsung", Price: 599},
+		{ID: 3, Name: "Phone Case", Category: "accessories", Brand: "Spigen", Price: 19},
+		{ID: 4, Name: "Desk Lamp", Category: "home", Brand: "Ikea", Description: "Charges your phone", Price: 39},
+		{ID: 5, Name: "Cotton T-Shirt", Category: "apparel", Brand: "Hanes", Price: 12},
+		{ID: 6, Name: "T Shirt Dress", Category: "apparel", Brand: "Hanes", Price: 30},
+	} {
+		s.Put(p)
+	}
+	return s
+}
+
+func TestScanMatchesSubstrings(t *testing.T) {
+	e := New(phones(), StrategyIndex)
+	tests := []struct {
+		query string
+		scan  []int
+		index []int
+	}{
+		{"phone", []int{1, 2, 3}, []int{3, 4}},
+		{"PHONE", []int{1, 2, 3}, []int{3, 4}},
+		{"phone -case", []int{1, 2}, []int{4}},
+		{"phone price:<100", []int{3}, []int{3, 4}},
+		{"brand:app OR lamp", []int{1, 4}, []int{4}},
+		{"smart", []int{1, 2}, nil},
+		{"t-shirt", []int{5}, []int{5, 6}},
+		{"T-SHIRT", []int{5}, []int{5, 6}},
+		{`"t shirt"`, []int{6}, []int{5, 6}},
+		{"name:t-shirt", []int{5}, []int{5, 6}},
+	}
+	for _, tt := range tests {
+		for _, strategy := range []Strategy{StrategyScan, StrategyIndex} {
+			res, err := e.Execute(context.Background(), Request{Query: tt.query, Strategy: strategy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, h := range res.Products {
+				got = append(got, h.ID)
+			}
+			slices.Sort(got)
+			want := tt.index
+			if strategy == StrategyScan {
+				want = tt.scan
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %q: got %v, want %v", strategy, tt.query, got, want)
+			}
+		}
+	}
+}
+
+// TestScanCacheKeepsPunctuation checks that queries with the same
+// tokens but different punctuation, which scan tells apart, are not
+// answered from each other's cache entries.
+func TestScanCacheKeepsPunctuation(t *testing.T) {
+	e := New(phones(), StrategyScan)
+	e.UseCache(NewCache(10, time.Minute))
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{{"t-shirt", 5}, {`"t shirt"`, 6}, {"t-shirt", 5}} {
+		res, err := e.Execute(context.Background(), Request{Query: tt.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Products) != 1 || res.Products[0].ID != tt.want {
+			t.Fatalf("%q: got %v, want product %d", tt.query, res.Products, tt.want)
+		}
+	}
+}
@@ -1,69 +1,458 @@
-// Package search implements bounded product search over the store.
+// Package search implements product search over the store.
 //
 // Design decision hidden: The search algorithm and iteration bounds.
-// Currently does case-insensitive substring matching over exactly
-// MaxCheck products, returning at most MaxResults. The matching
-// strategy (substring, regex, fuzzy, inverted index) and the
-// iteration bound are encapsulated here. Changing the algorithm
-// from O(n) scan to an inverted index would only require changes
-// in this module.
+// Queries are written in a small language with AND, OR, negation,
+// grouping, field scoping and price bounds (see query.go), parsed into
+// an AST. Two matching strategies are available and selectable per
+// request:
+//
+//	index → the AST is resolved by set operations over an inverted
+//	        index of Name, Category, Description and Brand and the
+//	        store's secondary indexes, reaching the whole catalog
+//	scan  → the AST is evaluated against exactly MaxCheck products,
+//	        matching terms as substrings of names and categories as
+//	        the original search did, kept for load-test comparisons
+//
+// Either strategy can be narrowed by structured Filters; with the index
+// strategy a filter-only query is answered entirely from the store's
+// secondary indexes. Facet counts can be requested over the full
+// matching set of either strategy. With Fuzzy set, query terms absent
+// from the index are first corrected to the nearest indexed term within
+// a bounded edit distance. Matches are scored with BM25F over Name,
+// Category, Brand and Description (see fieldBoosts) and returned in
+// descending relevance, one page at a time, optionally with the matched
+// tokens of each hit highlighted. Ranked matches can be cached, with
+// catalog writes invalidating exactly the entries they affect (see
+// Cache). The Engine also answers prefix suggestions from a trie of
+// names, brands and categories. It keeps the index and trie in sync by
+// subscribing to the store, so callers never touch them directly.
 package search
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"product-search/model"
 	"product-search/store"
 )
 
 const (
-	// MaxCheck is the number of products inspected per search.
+	// MaxCheck is the number of products inspected per scan search.
 	// This simulates a fixed-cost computation (e.g., running an
 	// AI model on each product). The assignment requires exactly 100.
 	MaxCheck = 100
 
-	// MaxResults caps the number of products returned in a response.
+	// MaxResults is the page size used when a request sets no Limit.
 	MaxResults = 20
+
+	// MaxLimit caps the page size a request may ask for.
+	MaxLimit = 100
+)
+
+// tracer starts the spans of searches.
+var tracer = otel.Tracer("product-search/search")
+
+// ErrInvalidRequest is wrapped by every error caused by the request
+// itself rather than by the engine, so transports can map it to a
+// client error.
+var ErrInvalidRequest = errors.New("invalid search request")
+
+// Strategy selects how candidate products are matched.
+type Strategy string
+
+const (
+	// StrategyIndex answers queries from the inverted index.
+	StrategyIndex Strategy = "index"
+
+	// StrategyScan inspects exactly MaxCheck products, matching query
+	// terms as substrings of their names and categories.
+	StrategyScan Strategy = "scan"
 )
 
+// ParseStrategy converts a strategy name into a Strategy.
+func ParseStrategy(name string) (Strategy, error) {
+	switch s := Strategy(strings.ToLower(name)); s {
+	case StrategyIndex, StrategyScan:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown search strategy %q (want %q or %q)", name, StrategyIndex, StrategyScan)
+}
+
+// Request describes a single search. At least one of Query or Filters
+// must be set. A zero Strategy selects the Engine's default and a zero
+// Limit selects MaxResults. Offset and Cursor are mutually exclusive
+// ways to pick the page. Facets requests aggregations over all matches,
+// Fuzzy enables typo correction of query terms and Highlight marks
+// where each hit matched.
+type Request struct {
+	Query     string
+	Filters   Filters
+	Strategy  Strategy
+	Limit     int
+	Offset    int
+	Cursor    *Cursor
+	Facets    bool
+	Fuzzy     bool
+	Highlight bool
+}
+
+// Hit is a matched product together with its relevance score and, if
+// requested, a Fragment for each field the query matched, keyed by
+// field name.
+type Hit struct {
+	model.Product
+	Score     float64             `json:"score"`
+	Highlight map[string]Fragment `json:"highlight,omitempty"`
+}
+
 // Result holds the outcome of a single search operation.
 type Result struct {
-	Products   []model.Product `json:"products"`
-	TotalFound int             `json:"total_found"`
-	SearchTime string          `json:"search_time"`
-	Checked    int             `json:"products_checked"`
+	Products    []Hit        `json:"products"`
+	TotalFound  int          `json:"total_found"`
+	SearchTime  string       `json:"search_time"`
+	Checked     int          `json:"products_checked"`
+	Strategy    Strategy     `json:"strategy"`
+	NextCursor  string       `json:"next_cursor,omitempty"`
+	Facets      *Facets      `json:"facets,omitempty"`
+	Corrections []Correction `json:"corrections,omitempty"`
+	Cached      bool         `json:"cached"`
+	TimedOut    bool         `json:"timed_out"`
+}
+
+// Engine executes searches against a store. The inverted index is
+// maintained regardless of the default strategy so that either one
+// can be chosen per request.
+type Engine struct {
+	store     store.Store
+	index     *Index
+	suggester *Suggester
+	strategy  Strategy
+	cache     *Cache // nil when caching is off
+}
+
+// New creates an Engine over s, indexing any products already in the
+// store and subscribing to future changes.
+func New(s store.Store, strategy Strategy) *Engine {
+	e := &Engine{
+		store:     s,
+		index:     NewIndex(),
+		suggester: NewSuggester(),
+		strategy:  strategy,
+	}
+	s.Subscribe(e.index)
+	s.Subscribe(e.suggester)
+	s.Iterate(context.Background(), math.MinInt, math.MaxInt, func(p model.Product) bool {
+		e.index.ProductPut(p)
+		e.suggester.ProductPut(p)
+		return true
+	})
+	return e
 }
 
-// Execute runs a bounded search for the given query string.
-// It checks exactly MaxCheck products and returns up to MaxResults matches.
-func Execute(s *store.ProductStore, query string) Result {
+// UseCache makes the Engine serve repeated searches from c, which it
+// subscribes to the store so writes invalidate it. Call it before the
+// Engine is used.
+func (e *Engine) UseCache(c *Cache) {
+	e.cache = c
+	e.store.Subscribe(c)
+}
+
+// CacheStats returns the counters of the Engine's cache, or false if
+// it has none.
+func (e *Engine) CacheStats() (CacheStats, bool) {
+	if e.cache == nil {
+		return CacheStats{}, false
+	}
+	return e.cache.Stats(), true
+}
+
+// Suggest returns up to limit completions for prefix drawn from
+// product names, brands and categories, most frequent first. limit is
+// clamped to MaxSuggestions.
+func (e *Engine) Suggest(prefix string, limit int) []Suggestion {
+	return e.suggester.Suggest(prefix, min(limit, MaxSuggestions))
+}
+
+// Execute runs a search using the requested or default strategy and
+// returns the requested page of hits. Errors wrap ErrInvalidRequest.
+//
+// If ctx is done before the search completes, Execute stops early and
+// returns what it has found, marked TimedOut. Those hits are genuine
+// matches, but the set may be incomplete (possibly empty) and its
+// ranking and counts cover only what was found.
+//
+// Each search is traced as a span under ctx's, recording the query
+// length and how many products were checked and found, with child
+// spans for matching, facets and loading the page from the store.
+func (e *Engine) Execute(ctx context.Context, req Request) (result Result, err error) {
 	start := time.Now()
-	queryLower := strings.ToLower(query)
+	ctx, span := tracer.Start(ctx, "search.Execute", trace.WithAttributes(
+		attribute.Int("search.query_length", utf8.RuneCountInString(req.Query)),
+		attribute.Bool("search.fuzzy", req.Fuzzy),
+		attribute.Bool("search.facets", req.Facets),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
-	var matches []model.Product
-	totalFound := 0
+	if req.Strategy == "" {
+		req.Strategy = e.strategy
+	}
+	if req.Limit == 0 {
+		req.Limit = MaxResults
+	}
+	if err := req.validate(); err != nil {
+		return Result{}, err
+	}
+
+	query, err := parseQuery(req.Query)
+	if err != nil {
+		return Result{}, err
+	}
+
+	key := req.cacheKey(query)
+	m, cached := e.cache.get(key)
+	if !cached {
+		version := e.cache.version()
+		m = e.match(ctx, req, query)
+		if !m.timedOut {
+			e.cache.put(key, req, m, version)
+		}
+	}
+
+	result = Result{
+		TotalFound:  len(m.hits),
+		Checked:     m.checked,
+		Corrections: m.corrections,
+		Cached:      cached,
+		TimedOut:    m.timedOut,
+	}
+	if req.Facets {
+		facets := m.facets.Load()
+		if facets == nil {
+			facets = e.facets(ctx, m.hits)
+			if ctx.Err() != nil {
+				result.TimedOut = true
+			} else {
+				m.facets.Store(facets)
+			}
+		}
+		result.Facets = facets
+	}
+	result.Products, result.NextCursor = e.page(ctx, m, req)
+	if req.Highlight && m.query != nil {
+		hl := newHighlighter(m.query)
+		for i := range result.Products {
+			result.Products[i].Highlight = hl.highlight(result.Products[i].Product)
+		}
+	}
+	result.Strategy = req.Strategy
+	result.SearchTime = time.Since(start).String()
+	span.SetAttributes(
+		attribute.String("search.strategy", string(req.Strategy)),
+		attribute.Int("search.products_checked", result.Checked),
+		attribute.Int("search.total_found", result.TotalFound),
+		attribute.Int("search.returned", len(result.Products)),
+		attribute.Bool("search.cached", result.Cached),
+		attribute.Bool("search.timed_out", result.TimedOut),
+	)
+	return result, nil
+}
+
+// match finds every product matching the query and filters and ranks
+// them. It applies fuzzy corrections to query in place.
+func (e *Engine) match(ctx context.Context, req Request, query node) *matches {
+	ctx, span := tracer.Start(ctx, "search.match", trace.WithAttributes(attribute.String("search.strategy", string(req.Strategy))))
+	defer span.End()
+	m := &matches{query: query}
+	if req.Fuzzy {
+		m.corrections = e.correct(query)
+	}
+	switch req.Strategy {
+	case StrategyScan:
+		m.hits, m.checked, m.lastChecked = e.scan(ctx, query, req.Filters)
+	default:
+		m.hits = e.lookup(ctx, query, req.Filters)
+		m.checked = len(m.hits)
+	}
+	m.timedOut = ctx.Err() != nil
+	slices.SortFunc(m.hits, compareScored)
+	return m
+}
+
+// validate checks the paging parameters of a normalized request.
+func (req Request) validate() error {
+	if err := req.Filters.validate(); err != nil {
+		return err
+	}
+	switch {
+	case strings.TrimSpace(req.Query) == "" && req.Filters.empty():
+		return fmt.Errorf("%w: a query or at least one filter is required", ErrInvalidRequest)
+	case req.Limit < 1 || req.Limit > MaxLimit:
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, MaxLimit)
+	case req.Offset < 0:
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidRequest)
+	case req.Cursor != nil && req.Offset > 0:
+		return fmt.Errorf("%w: offset and cursor cannot be combined", ErrInvalidRequest)
+	case req.Cursor != nil && req.Cursor.Query != req.fingerprint():
+		return fmt.Errorf("%w: cursor belongs to a different query", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// lookup resolves the query over the whole catalog and keeps the
+// matches that also satisfy the filters. Without a query, every
+// filtered product matches with a zero score.
+func (e *Engine) lookup(ctx context.Context, query node, filters Filters) []scored {
+	if query == nil {
+		ids := filters.ids(e.store)
+		hits := make([]scored, len(ids))
+		for i, id := range ids {
+			hits[i] = scored{id: id}
+		}
+		return hits
+	}
+
+	var hits []scored
+	if toks, ok := plainTerms(query); ok {
+		// The common case: the index intersects and scores in one pass.
+		hits = e.index.Lookup(ctx, toks)
+	} else {
+		ids := query.eval(&evalContext{ctx: ctx, index: e.index, store: e.store})
+		if ctx.Err() != nil {
+			return nil
+		}
+		hits = e.index.ScoreAll(ctx, ids, terms(query))
+	}
+	if filters.empty() || len(hits) == 0 {
+		return hits
+	}
+
+	// Index hits come back in ID order, so they merge directly with the
+	// sorted filter IDs.
+	ids := filters.ids(e.store)
+	out := hits[:0]
+	j := 0
+	for _, h := range hits {
+		for j < len(ids) && ids[j] < h.id {
+			j++
+		}
+		if j < len(ids) && ids[j] == h.id {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// scan checks exactly MaxCheck products and returns those matching
+// the query and the filters, along with the number checked and the
+// highest ID checked. A nil query matches every product.
+func (e *Engine) scan(ctx context.Context, query node, filters Filters) ([]scored, int, int) {
+	scoreTerms := terms(query)
+
+	var hits []scored
+	lastID := 0
 
 	// Iterate over exactly MaxCheck products via the store's iterator.
 	// The callback receives every product; we count ALL visited, not
 	// just matches (this is the "fixed computation" the assignment requires).
-	checked := s.Iterate(1, MaxCheck, func(p model.Product) bool {
-		nameLower := strings.ToLower(p.Name)
-		catLower := strings.ToLower(p.Category)
-
-		if strings.Contains(nameLower, queryLower) || strings.Contains(catLower, queryLower) {
-			totalFound++
-			if len(matches) < MaxResults {
-				matches = append(matches, p)
-			}
+	ctx, span := tracer.Start(ctx, "store.Iterate")
+	defer span.End()
+	checked := e.store.Iterate(ctx, 1, MaxCheck, func(p model.Product) bool {
+		lastID = p.ID
+		if (query == nil || scanMatch(query, p, newScanText(p))) && filters.match(p) {
+			hits = append(hits, scored{id: p.ID, score: e.index.Score(p.ID, scoreTerms)})
 		}
 		return true // always continue until MaxCheck is reached
 	})
+	span.SetAttributes(attribute.Int("store.products_visited", checked))
+
+	return hits, checked, lastID
+}
+
+// page loads the products of the requested page of m's ranked hits
+// from the store. It also returns the cursor for the following page,
+// if there is one.
+func (e *Engine) page(ctx context.Context, m *matches, req Request) ([]Hit, string) {
+	hits := m.hits
+	start := req.Offset
+	if req.Cursor != nil {
+		start = e.resume(m, *req.Cursor)
+	}
+	if start > len(hits) {
+		start = len(hits)
+	}
+	end := min(start+req.Limit, len(hits))
+
+	_, span := tracer.Start(ctx, "store.Get", trace.WithAttributes(attribute.Int("store.lookups", end-start)))
+	var products []Hit
+	for _, h := range hits[start:end] {
+		// A product may vanish between ranking and loading; skip it.
+		if p, ok := e.store.Get(h.id); ok {
+			products = append(products, Hit{Product: p, Score: h.score})
+		}
+	}
+	span.End()
 
-	return Result{
-		Products:   matches,
-		TotalFound: totalFound,
-		SearchTime: time.Since(start).String(),
-		Checked:    checked,
+	var next string
+	if end < len(hits) {
+		last := hits[end-1]
+		next = Cursor{Query: req.fingerprint(), Score: last.score, ID: last.id}.Encode()
 	}
+	return products, next
+}
+
+// resume returns the index of the first of m's hits after c.
+//
+// Every write changes the collection statistics and so every score,
+// which makes the score in c stale as soon as the catalog changes. The
+// position is therefore found by the cursor's product: right after it,
+// if it is still a hit, or else where its current score ranks it. Only
+// a product this engine does not hold, such as one from another shard
+// in a merged list, is placed by the score recorded in c.
+func (e *Engine) resume(m *matches, c Cursor) int {
+	for i, h := range m.hits {
+		if h.id == c.ID {
+			return i + 1
+		}
+	}
+	after := scored{id: c.ID, score: c.Score}
+	if e.index.contains(c.ID) {
+		after.score = 0
+		if m.query != nil {
+			after.score = e.index.Score(c.ID, terms(m.query))
+		}
+	}
+	i, _ := slices.BinarySearchFunc(m.hits, after, compareScored)
+	return i
+}
+
+// compareScored orders hits by descending score, then ascending ID.
+func compareScored(a, b scored) int {
+	if c := cmp.Compare(b.score, a.score); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+// CompareHits orders hits the way Execute ranks them, for callers
+// merging the results of several searches.
+func CompareHits(a, b Hit) int {
+	return compareScored(scored{id: a.ID, score: a.Score}, scored{id: b.ID, score: b.Score})
 }
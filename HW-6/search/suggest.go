@@ -0,0 +1,235 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"product-search/model"
+)
+
+// MaxSuggestions caps the completions returned for one prefix. Each
+// trie node caches this many, so lookups never walk a subtree.
+const MaxSuggestions = 10
+
+// Suggestion is a completion for a prefix and the number of products
+// whose name, brand or category it is.
+type Suggestion struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// completion is a distinct suggestable string. Its count changes as
+// products are written; trie nodes hold pointers to it.
+type completion struct {
+	text  string // display form, as first seen
+	count int
+}
+
+// trieNode is a node in a byte-wise trie over lower-cased completions.
+// top caches the best completions in the node's subtree and is kept
+// current on every write.
+type trieNode struct {
+	labels   []byte // sorted edge labels, parallel to children
+	children []*trieNode
+	entry    *completion // set if a completion ends here
+	top      []*completion
+}
+
+// Suggester answers prefix completions over product names, brands and
+// categories, ranked by how many products carry them. It implements
+// store.Observer so it is built as the store is populated. Safe for
+// concurrent use.
+type Suggester struct {
+	mu    sync.RWMutex
+	root  *trieNode
+	byID  map[int][3]string // product ID → keys it contributed
+	byKey map[string]*completion
+}
+
+// NewSuggester creates an empty Suggester.
+func NewSuggester() *Suggester {
+	return &Suggester{
+		root:  &trieNode{},
+		byID:  make(map[int][3]string),
+		byKey: make(map[string]*completion),
+	}
+}
+
+// ProductPut counts the product's name, brand and category, replacing
+// the contribution of any previous version with the same ID.
+func (sg *Suggester) ProductPut(p model.Product) {
+	texts := [3]string{p.Name, p.Brand, p.Category}
+	var keys [3]string
+	for i, t := range texts {
+		keys[i] = strings.ToLower(strings.TrimSpace(t))
+	}
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if old, ok := sg.byID[p.ID]; ok {
+		if old == keys {
+			return
+		}
+		for _, k := range old {
+			sg.decrement(k)
+		}
+	}
+	for i, k := range keys {
+		sg.increment(k, texts[i])
+	}
+	sg.byID[p.ID] = keys
+}
+
+// ProductDeleted withdraws the product's name, brand and category.
+func (sg *Suggester) ProductDeleted(p model.Product) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if old, ok := sg.byID[p.ID]; ok {
+		for _, k := range old {
+			sg.decrement(k)
+		}
+		delete(sg.byID, p.ID)
+	}
+}
+
+// increment adds one to the count of key, creating it if needed, and
+// promotes it in the cached top lists along its path. Callers must
+// hold sg.mu.
+func (sg *Suggester) increment(key, text string) {
+	if key == "" {
+		return
+	}
+	c, ok := sg.byKey[key]
+	if !ok {
+		c = &completion{text: strings.TrimSpace(text)}
+		sg.byKey[key] = c
+	}
+	c.count++
+
+	n := sg.root
+	for i := 0; ; i++ {
+		n.top = promote(n.top, c)
+		if i == len(key) {
+			break
+		}
+		n = n.child(key[i], true)
+	}
+	n.entry = c
+}
+
+// decrement subtracts one from the count of key. A lower count can let
+// a completion outside a node's top list overtake it, so each node on
+// the path whose list holds key is repaired from its children's lists,
+// deepest first. Only the path is touched. Callers must hold sg.mu.
+func (sg *Suggester) decrement(key string) {
+	c, ok := sg.byKey[key]
+	if !ok {
+		return
+	}
+	c.count--
+
+	path := make([]*trieNode, 0, len(key)+1)
+	n := sg.root
+	for i := 0; n != nil; i++ {
+		path = append(path, n)
+		if i == len(key) {
+			break
+		}
+		n = n.child(key[i], false)
+	}
+	for _, n := range slices.Backward(path) {
+		if slices.Contains(n.top, c) {
+			n.repair()
+		}
+	}
+}
+
+// Suggest returns up to limit completions starting with prefix, most
+// frequent first. Matching is case-insensitive.
+func (sg *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	key := strings.ToLower(strings.TrimLeft(prefix, " "))
+
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+	n := sg.find(key)
+	if n == nil {
+		return nil
+	}
+	return suggestions(n.top, limit)
+}
+
+// find returns the node for key, or nil. Callers must hold sg.mu.
+func (sg *Suggester) find(key string) *trieNode {
+	n := sg.root
+	for i := 0; i < len(key) && n != nil; i++ {
+		n = n.child(key[i], false)
+	}
+	return n
+}
+
+// child returns the child along label b, creating it if asked to.
+func (n *trieNode) child(b byte, create bool) *trieNode {
+	i := sort.Search(len(n.labels), func(i int) bool { return n.labels[i] >= b })
+	if i < len(n.labels) && n.labels[i] == b {
+		return n.children[i]
+	}
+	if !create {
+		return nil
+	}
+	c := &trieNode{}
+	n.labels = slices.Insert(n.labels, i, b)
+	n.children = slices.Insert(n.children, i, c)
+	return c
+}
+
+// repair rebuilds the top list of n from its own completion and its
+// children's top lists, which must be current. The best completions of
+// a subtree are among those, since subtrees do not overlap.
+func (n *trieNode) repair() {
+	top := n.top[:0]
+	if n.entry != nil && n.entry.count > 0 {
+		top = append(top, n.entry)
+	}
+	for _, c := range n.children {
+		top = append(top, c.top...)
+	}
+	slices.SortFunc(top, compareCompletions)
+	n.top = slices.Clip(top[:min(len(top), MaxSuggestions)])
+}
+
+// promote places c in the ranked list top if it belongs there, keeping
+// at most MaxSuggestions entries.
+func promote(top []*completion, c *completion) []*completion {
+	if c.count <= 0 {
+		return top
+	}
+	if !slices.Contains(top, c) {
+		if len(top) == MaxSuggestions && compareCompletions(c, top[len(top)-1]) > 0 {
+			return top
+		}
+		top = append(top, c)
+	}
+	slices.SortFunc(top, compareCompletions)
+	return top[:min(len(top), MaxSuggestions)]
+}
+
+// compareCompletions orders by descending count, then by text.
+func compareCompletions(a, b *completion) int {
+	if c := cmp.Compare(b.count, a.count); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.text, b.text)
+}
+
+func suggestions(top []*completion, limit int) []Suggestion {
+	out := make([]Suggestion, 0, min(limit, len(top)))
+	for _, c := range top[:min(limit, len(top))] {
+		out = append(out, Suggestion{Text: c.text, Count: c.count})
+	}
+	return out
+}
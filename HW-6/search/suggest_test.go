@@ -0,0 +1,123 @@
+package search
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"product-search/model"
+)
+
+// bruteSuggest ranks every name, brand and category in products that
+// starts with prefix, as Suggest should.
+func bruteSuggest(products map[int]model.Product, prefix string, limit int) []Suggestion {
+	counts := make(map[string]*Suggestion)
+	for _, p := range products {
+		for _, t := range []string{p.Name, p.Brand, p.Category} {
+			key := strings.ToLower(t)
+			if key == "" || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if counts[key] == nil {
+				counts[key] = &Suggestion{Text: t}
+			}
+			counts[key].Count++
+		}
+	}
+	var all []Suggestion
+	for _, s := range counts {
+		all = append(all, *s)
+	}
+	slices.SortFunc(all, func(a, b Suggestion) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Text, b.Text)
+	})
+	return all[:min(len(all), limit)]
+}
+
+// TestSuggesterMatchesBruteForce applies random puts, updates and
+// deletes and checks every prefix against a full recount after each.
+func TestSuggesterMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	words := []string{"apple", "apricot", "app", "banana", "band", "bandana", "cable", "cab", "a", "b"}
+	random := func(id int) model.Product {
+		return model.Product{
+			ID:       id,
+			Name:     words[r.IntN(len(words))] + " " + words[r.IntN(len(words))],
+			Brand:    words[r.IntN(len(words))],
+			Category: words[r.IntN(3)],
+		}
+	}
+
+	sg := NewSuggester()
+	products := make(map[int]model.Product)
+	prefixes := []string{"", "a", "ap", "app", "apple ", "b", "ban", "band", "c", "cab", "z"}
+	for step := range 3000 {
+		id := r.IntN(60)
+		if old, ok := products[id]; ok && r.IntN(3) == 0 {
+			sg.ProductDeleted(old)
+			delete(products, id)
+		} else {
+			p := random(id)
+			sg.ProductPut(p)
+			products[id] = p
+		}
+		for _, prefix := range prefixes {
+			got := sg.Suggest(prefix, MaxSuggestions)
+			want := bruteSuggest(products, prefix, MaxSuggestions)
+			if !slices.Equal(got, want) && !(len(got) == 0 && len(want) == 0) {
+				t.Fatalf("step %d, prefix %q:\n got %v\nwant %v", step, prefix, got, want)
+			}
+		}
+	}
+}
+
+func TestSuggestLimitAndCase(t *testing.T) {
+	sg := NewSuggester()
+	for id := 1; id <= 30; id++ {
+		sg.ProductPut(model.Product{ID: id, Name: fmt.Sprintf("Widget %02d", id%15), Brand: "Acme", Category: "Tools"})
+	}
+	if got := sg.Suggest("WIDGET", 3); len(got) != 3 || got[0].Text != "Widget 00" || got[0].Count != 2 {
+		t.Fatalf("Suggest(WIDGET, 3) = %v", got)
+	}
+	if got := sg.Suggest("acme", 5); len(got) != 1 || got[0] != (Suggestion{Text: "Acme", Count: 30}) {
+		t.Fatalf("Suggest(acme) = %v", got)
+	}
+	if got := sg.Suggest("zzz", 5); got != nil {
+		t.Fatalf("Suggest(zzz) = %v, want nothing", got)
+	}
+}
+
+// TestSuggesterConcurrent runs writers and readers together; run it
+// with -race.
+func TestSuggesterConcurrent(t *testing.T) {
+	sg := NewSuggester()
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Go(func() {
+			for i := range 500 {
+				p := model.Product{ID: w*1000 + i%50, Name: fmt.Sprintf("Widget %d", i%7), Brand: "Acme"}
+				sg.ProductPut(p)
+				if i%4 == 0 {
+					sg.ProductDeleted(p)
+				}
+			}
+		})
+		wg.Go(func() {
+			for range 500 {
+				for _, s := range sg.Suggest("w", MaxSuggestions) {
+					if s.Count <= 0 {
+						t.Errorf("suggested %q with count %d", s.Text, s.Count)
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -1,16 +1,24 @@
-// Package seeddata fetches the base product catalog from DummyJSON API.
+// Package seeddata loads the base product catalog that the generator
+// expands.
 //
 // Design decision hidden: Where seed data comes from and how it's parsed.
-// Currently pulls all products from https://dummyjson.com/products at startup.
-// Could be swapped to read from a file, database, or other API without
-// affecting any other module.
+// The SEED_SOURCE environment variable selects a Source:
+//
+//	(unset), "dummyjson"  → https://dummyjson.com/products, falling back
+//	                        to the embedded catalog if the call fails
+//	http:// or https://   → any URL serving the DummyJSON response shape,
+//	                        with the same fallback
+//	"embedded"            → the catalog compiled into the binary
+//	a file path           → .json, .csv or .ndjson/.jsonl seed file
+//	a directory path      → every seed file in it, in name order
+//
+// No other module knows which source was used.
 package seeddata
 
 import (
-	"encoding/json"
-	"io"
+	"fmt"
 	"log"
-	"net/http"
+	"os"
 )
 
 // SeedProduct holds the template data for generating product variants.
@@ -22,68 +30,23 @@ type SeedProduct struct {
 	Price       float64
 }
 
-// dummyJSONResponse matches the DummyJSON API response shape.
-type dummyJSONResponse struct {
-	Products []dummyJSONProduct `json:"products"`
-	Total    int                `json:"total"`
-}
-
-// dummyJSONProduct matches a single product from DummyJSON.
-type dummyJSONProduct struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Category    string  `json:"category"`
-	Price       float64 `json:"price"`
-	Brand       string  `json:"brand"`
-}
-
-// apiURL fetches all products with only the fields we need.
-const apiURL = "https://dummyjson.com/products?limit=0&select=title,description,category,price,brand"
-
-// Load fetches all products from DummyJSON and returns them as seeds.
+// Load reads seeds from the source named by SEED_SOURCE. The service
+// cannot start without seeds, so any failure is fatal.
 func Load() []SeedProduct {
-	log.Println("Fetching seed products from DummyJSON API...")
-
-	resp, err := http.Get(apiURL)
+	src, err := Parse(os.Getenv("SEED_SOURCE"))
 	if err != nil {
-		log.Fatalf("Failed to fetch from DummyJSON: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Fatalf("DummyJSON returned status %d: %s", resp.StatusCode, string(body))
+		log.Fatalf("Invalid SEED_SOURCE: %v", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	log.Printf("Loading seed products from %s...\n", src)
+	seeds, err := src.Load()
 	if err != nil {
-		log.Fatalf("Failed to read response body: %v", err)
+		log.Fatalf("Failed to load seed products: %v", err)
 	}
-
-	var apiResp dummyJSONResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		log.Fatalf("Failed to parse DummyJSON response: %v", err)
-	}
-
-	seeds := make([]SeedProduct, 0, len(apiResp.Products))
-	for _, p := range apiResp.Products {
-		brand := p.Brand
-		if brand == "" {
-			brand = "Generic"
-		}
-		seeds = append(seeds, SeedProduct{
-			Name:        p.Title,
-			Category:    p.Category,
-			Description: p.Description,
-			Brand:       brand,
-			Price:       p.Price,
-		})
-	}
-
-	log.Printf("Fetched %d seed products from DummyJSON (total available: %d)\n", len(seeds), apiResp.Total)
 	if len(seeds) == 0 {
-		log.Fatal("No seed products fetched — cannot start service")
+		log.Fatal("No seed products loaded — cannot start service")
 	}
+	log.Printf("Loaded %d seed products\n", len(seeds))
 
 	// Log category distribution — use log.Printf for consistency
 	categories := make(map[string]int)
@@ -94,3 +57,17 @@ func Load() []SeedProduct {
 
 	return seeds
 }
+
+// normalize fills defaults and rejects seeds the generator cannot use.
+func normalize(s SeedProduct) (SeedProduct, error) {
+	if s.Name == "" {
+		return s, fmt.Errorf("seed has no name")
+	}
+	if s.Price < 0 {
+		return s, fmt.Errorf("seed %q has negative price", s.Name)
+	}
+	if s.Brand == "" {
+		s.Brand = "Generic"
+	}
+	return s, nil
+}
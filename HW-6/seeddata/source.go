@@ -0,0 +1,319 @@
+package seeddata
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source yields seed products.
+type Source interface {
+	Load() ([]SeedProduct, error)
+	String() string
+}
+
+// apiURL fetches all products with only the fields we need.
+const apiURL = "https://dummyjson.com/products?limit=0&select=title,description,category,price,brand"
+
+// fetchTimeout bounds the startup call to a seed URL so an unreachable
+// host falls back promptly instead of hanging.
+const fetchTimeout = 10 * time.Second
+
+//go:embed catalog.json
+var embeddedCatalog []byte
+
+// Parse returns the Source described by spec; see the package doc.
+func Parse(spec string) (Source, error) {
+	switch {
+	case spec == "" || spec == "dummyjson":
+		return Fallback{Primary: URL(apiURL), Secondary: Embedded{}}, nil
+	case spec == "embedded":
+		return Embedded{}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return Fallback{Primary: URL(spec), Secondary: Embedded{}}, nil
+	}
+
+	info, err := os.Stat(spec)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return Dir(spec), nil
+	}
+	return File(spec), nil
+}
+
+// URL fetches seeds from an endpoint serving the DummyJSON response shape.
+type URL string
+
+func (u URL) String() string { return string(u) }
+
+// Load fetches and parses the seeds.
+func (u URL) Load() ([]SeedProduct, error) {
+	client := http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(string(u))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned status %d: %s", u, resp.StatusCode, string(body))
+	}
+	return parseJSON(resp.Body)
+}
+
+// Embedded returns the default catalog compiled into the binary. It is
+// modelled on the DummyJSON catalog and covers the same categories.
+type Embedded struct{}
+
+func (Embedded) String() string { return "embedded catalog" }
+
+// Load parses the embedded catalog.
+func (Embedded) Load() ([]SeedProduct, error) {
+	return parseJSON(bytes.NewReader(embeddedCatalog))
+}
+
+// Fallback loads from Primary, or from Secondary if Primary fails.
+type Fallback struct {
+	Primary   Source
+	Secondary Source
+}
+
+func (f Fallback) String() string {
+	return fmt.Sprintf("%s (falling back to %s)", f.Primary, f.Secondary)
+}
+
+// Load tries Primary first. An empty result counts as a failure.
+func (f Fallback) Load() ([]SeedProduct, error) {
+	seeds, err := f.Primary.Load()
+	if err == nil && len(seeds) > 0 {
+		return seeds, nil
+	}
+	if err == nil {
+		err = errors.New("no seed products")
+	}
+	log.Printf("Seed source %s failed (%v); using %s\n", f.Primary, err, f.Secondary)
+	return f.Secondary.Load()
+}
+
+// File reads seeds from a local file. The format follows the
+// extension: .json (DummyJSON response or a bare array), .csv (header
+// row naming the columns) or .ndjson/.jsonl (one product per line).
+type File string
+
+func (f File) String() string { return string(f) }
+
+// Load opens and parses the file.
+func (f File) Load() ([]SeedProduct, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var seeds []SeedProduct
+	switch strings.ToLower(filepath.Ext(string(f))) {
+	case ".json":
+		seeds, err = parseJSON(file)
+	case ".csv":
+		seeds, err = parseCSV(file)
+	case ".ndjson", ".jsonl":
+		seeds, err = parseNDJSON(file)
+	default:
+		return nil, fmt.Errorf("%s: unsupported seed file type", f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f, err)
+	}
+	return seeds, nil
+}
+
+// Dir reads every seed file in a directory, in name order. Files with
+// unsupported extensions are skipped.
+type Dir string
+
+func (d Dir) String() string { return string(d) + "/" }
+
+// Load reads and concatenates the directory's seed files.
+func (d Dir) Load() ([]SeedProduct, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var seeds []SeedProduct
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".csv", ".ndjson", ".jsonl":
+		default:
+			continue
+		}
+		more, err := File(filepath.Join(string(d), e.Name())).Load()
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, more...)
+	}
+	return seeds, nil
+}
+
+// dummyJSONResponse matches the DummyJSON API response shape.
+type dummyJSONResponse struct {
+	Products []dummyJSONProduct `json:"products"`
+	Total    int                `json:"total"`
+}
+
+// dummyJSONProduct matches a single product from DummyJSON. Name is
+// accepted as an alias of Title for hand-written seed files.
+type dummyJSONProduct struct {
+	Title       string  `json:"title"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Price       float64 `json:"price"`
+	Brand       string  `json:"brand"`
+}
+
+func (p dummyJSONProduct) seed() SeedProduct {
+	name := p.Title
+	if name == "" {
+		name = p.Name
+	}
+	return SeedProduct{
+		Name:        name,
+		Category:    p.Category,
+		Description: p.Description,
+		Brand:       p.Brand,
+		Price:       p.Price,
+	}
+}
+
+// parseJSON reads a DummyJSON response object or a bare array of
+// products.
+func parseJSON(r io.Reader) ([]SeedProduct, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []dummyJSONProduct
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &products)
+	} else {
+		var resp dummyJSONResponse
+		err = json.Unmarshal(body, &resp)
+		products = resp.Products
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON seeds: %w", err)
+	}
+
+	seeds := make([]SeedProduct, 0, len(products))
+	for i, p := range products {
+		s, err := normalize(p.seed())
+		if err != nil {
+			return nil, fmt.Errorf("product %d: %w", i+1, err)
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, nil
+}
+
+// parseNDJSON reads one product object per line. Blank lines are skipped.
+func parseNDJSON(r io.Reader) ([]SeedProduct, error) {
+	var seeds []SeedProduct
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var p dummyJSONProduct
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		s, err := normalize(p.seed())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, sc.Err()
+}
+
+// parseCSV reads a header row followed by one product per row. The
+// header names the columns: title (or name), description, category,
+// price and brand, in any order. Only title/name is required.
+func parseCSV(r io.Reader) ([]SeedProduct, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		if i, ok := cols["name"]; ok {
+			cols["title"] = i
+		} else {
+			return nil, errors.New("CSV header needs a title or name column")
+		}
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+
+	var seeds []SeedProduct
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var price float64
+		if v := field(rec, "price"); v != "" {
+			if price, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid price %q", line, v)
+			}
+		}
+		s, err := normalize(SeedProduct{
+			Name:        field(rec, "title"),
+			Category:    field(rec, "category"),
+			Description: field(rec, "description"),
+			Brand:       field(rec, "brand"),
+			Price:       price,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, nil
+}
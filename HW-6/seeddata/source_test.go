@@ -0,0 +1,140 @@
+package seeddata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes name → content files to a temporary directory and
+// returns its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParse(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"seeds.csv": "name\nMug\n"})
+	tests := []struct {
+		spec string
+		want Source
+	}{
+		{"", Fallback{Primary: URL(apiURL), Secondary: Embedded{}}},
+		{"dummyjson", Fallback{Primary: URL(apiURL), Secondary: Embedded{}}},
+		{"embedded", Embedded{}},
+		{"https://example.com/p", Fallback{Primary: URL("https://example.com/p"), Secondary: Embedded{}}},
+		{dir, Dir(dir)},
+		{filepath.Join(dir, "seeds.csv"), File(filepath.Join(dir, "seeds.csv"))},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.spec); err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+	if _, err := Parse(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Error("a missing path parsed")
+	}
+}
+
+func TestFileFormats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.csv": "price, Category ,name,brand\n" +
+			"9.5,Kitchen,Mug,Acme\n" +
+			"12,Kitchen,\"Bowl, Large\",\n",
+		"b.json": `{"products":[{"title":"Lamp","category":"Home","price":30,"brand":"Lumen"}],` +
+			`"total":1}`,
+		"c.json": `[{"name":"Chair","category":"Home","price":80}]`,
+		"d.ndjson": `{"title":"Desk","category":"Home","price":200}` + "\n\n" +
+			`{"title":"Rug","price":60}` + "\n",
+		"notes.txt": "ignored",
+	})
+	seeds, err := Dir(dir).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SeedProduct{
+		{Name: "Mug", Category: "Kitchen", Brand: "Acme", Price: 9.5},
+		{Name: "Bowl, Large", Category: "Kitchen", Brand: "Generic", Price: 12},
+		{Name: "Lamp", Category: "Home", Brand: "Lumen", Price: 30},
+		{Name: "Chair", Category: "Home", Brand: "Generic", Price: 80},
+		{Name: "Desk", Category: "Home", Brand: "Generic", Price: 200},
+		{Name: "Rug", Brand: "Generic", Price: 60},
+	}
+	if len(seeds) != len(want) {
+		t.Fatalf("loaded %d seeds, want %d: %+v", len(seeds), len(want), seeds)
+	}
+	for i := range want {
+		if seeds[i] != want[i] {
+			t.Errorf("seed %d = %+v, want %+v", i, seeds[i], want[i])
+		}
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"no-name.csv":   "category,price\nKitchen,3\n",
+		"bad-price.csv": "name,price\nMug,cheap\n",
+		"negative.json": `[{"title":"Mug","price":-1}]`,
+		"nameless.json": `[{"category":"Home"}]`,
+		"broken.ndjson": "{\"title\":\"Mug\"}\n{oops\n",
+		"seeds.xml":     "<seeds/>",
+	})
+	for name, want := range map[string]string{
+		"no-name.csv":   "title or name column",
+		"bad-price.csv": `line 2: invalid price "cheap"`,
+		"negative.json": "negative price",
+		"nameless.json": "no name",
+		"broken.ndjson": "line 2",
+		"seeds.xml":     "unsupported",
+	} {
+		_, err := File(filepath.Join(dir, name)).Load()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: %v, want an error mentioning %q", name, err, want)
+		}
+	}
+}
+
+// TestFallback checks that an unreachable, failing or empty primary
+// source falls back to the embedded catalog, so startup needs no
+// network.
+func TestFallback(t *testing.T) {
+	embedded, err := Embedded{}.Load()
+	if err != nil || len(embedded) == 0 {
+		t.Fatalf("embedded catalog: %d seeds, %v", len(embedded), err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"products":[],"total":0}`))
+	}))
+	defer empty.Close()
+	serving := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"products":[{"title":"Mug","category":"Kitchen","price":4}],"total":1}`))
+	}))
+	defer serving.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	for _, u := range []string{failing.URL, empty.URL, unreachable.URL} {
+		seeds, err := Fallback{Primary: URL(u), Secondary: Embedded{}}.Load()
+		if err != nil || len(seeds) != len(embedded) {
+			t.Errorf("%s: %d seeds, %v; want the %d embedded", u, len(seeds), err, len(embedded))
+		}
+	}
+	seeds, err := Fallback{Primary: URL(serving.URL), Secondary: Embedded{}}.Load()
+	if err != nil || len(seeds) != 1 || seeds[0].Name != "Mug" {
+		t.Errorf("a serving primary gave %+v, %v", seeds, err)
+	}
+}
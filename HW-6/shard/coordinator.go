@@ -0,0 +1,387 @@
+package shard
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"product-search/search"
+)
+
+var tracer = otel.Tracer("product-search/shard")
+
+// ErrNoShards is returned when no shard could answer a search.
+var ErrNoShards = errors.New("no shard answered")
+
+// maxErrorBytes bounds how much of a failed shard response is read.
+const maxErrorBytes = 4 << 10
+
+// Result is a search result merged from every shard that answered.
+// Partial is set if any shard failed to; its products are then missing
+// from the hits and counts. TimedOut is set if any shard ran out of
+// time. Checked sums the products the shards checked, and a scan
+// checks search.MaxCheck products on each shard.
+type Result struct {
+	search.Result
+	Partial bool   `json:"partial"`
+	Shards  Status `json:"shards"`
+}
+
+// Status reports how many shards a search reached.
+type Status struct {
+	Total     int       `json:"total"`
+	Succeeded int       `json:"succeeded"`
+	Failures  []Failure `json:"failures,omitempty"`
+}
+
+// Failure is a shard that did not answer a search.
+type Failure struct {
+	Shard int    `json:"shard"`
+	Error string `json:"error"`
+}
+
+// InvalidError is a search a shard rejected as invalid. Every shard
+// would, so it is returned as is rather than treated as a failure.
+// Position is the offset of a query syntax error, if that was the
+// problem.
+type InvalidError struct {
+	Message  string `json:"error"`
+	Position *int   `json:"position,omitempty"`
+}
+
+func (e *InvalidError) Error() string { return e.Message }
+
+func (e *InvalidError) Unwrap() error { return search.ErrInvalidRequest }
+
+// Coordinator serves searches over a sharded catalog by querying every
+// shard through its HTTP API and merging the ranked results. Safe for
+// concurrent use.
+type Coordinator struct {
+	shards  []*url.URL
+	proxies []*httputil.ReverseProxy
+	client  *http.Client
+	apiKey  string
+	timeout time.Duration // for searches without a deadline
+	turn    atomic.Uint64 // picks the shard for the next new product
+}
+
+// NewCoordinator creates a Coordinator over the shards cfg lists.
+func NewCoordinator(cfg Config) *Coordinator {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 64
+	c := &Coordinator{
+		shards:  cfg.Shards,
+		client:  &http.Client{Transport: transport},
+		apiKey:  cfg.APIKey,
+		timeout: cmp.Or(cfg.Timeout, DefaultTimeout),
+	}
+	for _, u := range cfg.Shards {
+		proxy := httputil.NewSingleHostReverseProxy(u)
+		proxy.Transport = transport
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+		}
+		c.proxies = append(c.proxies, proxy)
+	}
+	return c
+}
+
+// Proxy returns a handler forwarding requests about product id to the
+// shard that owns it.
+func (c *Coordinator) Proxy(id int) http.Handler {
+	return c.proxies[Owner(id, len(c.shards))]
+}
+
+// ProxyInsert returns a handler forwarding the creation of product id
+// to the shard that owns it. A product without an ID goes to each
+// shard in turn, which gives it an ID from its own partition.
+func (c *Coordinator) ProxyInsert(id int) http.Handler {
+	if id != 0 {
+		return c.Proxy(id)
+	}
+	return c.proxies[(c.turn.Add(1)-1)%uint64(len(c.proxies))]
+}
+
+// Search runs req on every shard at once and merges the results into
+// the requested page. The shards rank the same way, so merging their
+// top hits ranks the whole catalog. Each shard scores with its own
+// collection statistics, though, so scores, and the order of equally
+// relevant products, differ slightly from an unsharded catalog. Every
+// shard must return Offset+Limit hits for the merge, so that sum is
+// capped at search.MaxLimit; deeper pages take a cursor, which every
+// shard accepts.
+//
+// Shards get most of ctx's remaining time to search, and return
+// partial results if they run out; a shard that has not answered by
+// the deadline, or that fails, is left out of a Partial result. A ctx
+// without a deadline gets the Config's Timeout, so a shard that hangs
+// is left out rather than holding the search. If no shard answers,
+// Search returns ErrNoShards.
+//
+// The search is traced like search.Engine.Execute, with a client span
+// for each shard continued by the shard's own spans.
+func (c *Coordinator) Search(ctx context.Context, req search.Request) (result Result, err error) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "shard.Search", trace.WithAttributes(
+		attribute.Int("search.query_length", utf8.RuneCountInString(req.Query)),
+		attribute.Int("shard.count", len(c.shards)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(
+				attribute.Int("search.products_checked", result.Checked),
+				attribute.Int("search.total_found", result.TotalFound),
+				attribute.Int("shard.succeeded", result.Shards.Succeeded),
+				attribute.Bool("search.timed_out", result.TimedOut),
+			)
+		}
+		span.End()
+	}()
+	if req.Limit == 0 {
+		req.Limit = search.MaxResults
+	}
+	if req.Cursor != nil && req.Offset > 0 {
+		return Result{}, fmt.Errorf("%w: offset and cursor cannot be combined", search.ErrInvalidRequest)
+	}
+	if req.Offset < 0 || req.Offset+req.Limit > search.MaxLimit {
+		return Result{}, fmt.Errorf("%w: offset plus limit must be at most %d on a sharded catalog; page with cursor instead", search.ErrInvalidRequest, search.MaxLimit)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	params := shardParams(ctx, req)
+
+	replies := make([]struct {
+		result search.Result
+		err    error
+	}, len(c.shards))
+	var wg sync.WaitGroup
+	for i, u := range c.shards {
+		wg.Go(func() {
+			replies[i].result, replies[i].err = c.query(ctx, i, u, params)
+		})
+	}
+	wg.Wait()
+
+	out := Result{Shards: Status{Total: len(c.shards)}}
+	out.Cached = true
+	var hits []search.Hit
+	more := false
+	for i, r := range replies {
+		var invalid *InvalidError
+		switch {
+		case errors.As(r.err, &invalid):
+			return Result{}, invalid
+		case r.err != nil:
+			out.Shards.Failures = append(out.Shards.Failures, Failure{Shard: i, Error: r.err.Error()})
+			continue
+		}
+		out.Shards.Succeeded++
+		res := r.result
+		hits = append(hits, res.Products...)
+		more = more || res.NextCursor != ""
+		out.TotalFound += res.TotalFound
+		out.Checked += res.Checked
+		out.Strategy = res.Strategy
+		out.Cached = out.Cached && res.Cached
+		out.TimedOut = out.TimedOut || res.TimedOut
+		out.Facets = mergeFacets(out.Facets, res.Facets)
+		for _, corr := range res.Corrections {
+			if !slices.Contains(out.Corrections, corr) {
+				out.Corrections = append(out.Corrections, corr)
+			}
+		}
+	}
+	if out.Shards.Succeeded == 0 {
+		return Result{}, fmt.Errorf("%w: %s", ErrNoShards, out.Shards.Failures[0].Error)
+	}
+	out.Partial = len(out.Shards.Failures) > 0
+
+	slices.SortFunc(hits, search.CompareHits)
+	from := min(req.Offset, len(hits))
+	to := min(req.Offset+req.Limit, len(hits))
+	out.Products = hits[from:to]
+	if (more || to < len(hits)) && to > from {
+		req.Strategy = out.Strategy
+		out.NextCursor = req.CursorAfter(out.Products[len(out.Products)-1])
+	}
+	out.SearchTime = time.Since(start).String()
+	return out, nil
+}
+
+// shardParams encodes req as a shard's search parameters, asking for
+// enough hits to fill the merged page. A shard gets three quarters of
+// the time left before ctx's deadline, leaving the rest for its reply
+// to arrive and be merged.
+func shardParams(ctx context.Context, req search.Request) url.Values {
+	params := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	set("q", req.Query)
+	set("category", req.Filters.Category)
+	set("brand", req.Filters.Brand)
+	if req.Filters.MinPrice != nil {
+		set("min_price", strconv.FormatFloat(*req.Filters.MinPrice, 'g', -1, 64))
+	}
+	if req.Filters.MaxPrice != nil {
+		set("max_price", strconv.FormatFloat(*req.Filters.MaxPrice, 'g', -1, 64))
+	}
+	set("strategy", string(req.Strategy))
+	set("limit", strconv.Itoa(req.Offset+req.Limit))
+	if req.Cursor != nil {
+		set("cursor", req.Cursor.Encode())
+	}
+	for name, on := range map[string]bool{"facets": req.Facets, "fuzzy": req.Fuzzy, "highlight": req.Highlight} {
+		if on {
+			set(name, "true")
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds() * 3 / 4
+		set("timeout_ms", strconv.FormatInt(max(ms, 1), 10))
+	}
+	return params
+}
+
+// query runs a search on shard i at base.
+func (c *Coordinator) query(ctx context.Context, i int, base *url.URL, params url.Values) (result search.Result, err error) {
+	ctx, span := tracer.Start(ctx, "shard.query", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.Int("shard.index", i),
+		attribute.String("server.address", base.Host),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	u := base.JoinPath("products", "search")
+	u.RawQuery = params.Encode()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return search.Result{}, err
+	}
+	resp, err := c.do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return search.Result{}, fmt.Errorf("no answer from %s before the deadline", base.Host)
+		}
+		return search.Result{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return search.Result{}, fmt.Errorf("reading answer from %s: %w", base.Host, err)
+		}
+		return result, nil
+	case http.StatusBadRequest:
+		invalid := &InvalidError{}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBytes)).Decode(invalid); err != nil || invalid.Message == "" {
+			invalid.Message = search.ErrInvalidRequest.Error()
+		}
+		return search.Result{}, invalid
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxErrorBytes)).Decode(&body)
+	return search.Result{}, fmt.Errorf("%s answered %s: %s", base.Host, resp.Status, body.Error)
+}
+
+// do sends a request of the coordinator's own to a shard, in the trace
+// of the request's context.
+func (c *Coordinator) do(req *http.Request) (*http.Response, error) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	return c.client.Do(req)
+}
+
+// mergeFacets adds the counts of b to a, which may be nil.
+func mergeFacets(a, b *search.Facets) *search.Facets {
+	switch {
+	case b == nil:
+		return a
+	case a == nil:
+		return b
+	}
+	for k, n := range b.Categories {
+		a.Categories[k] += n
+	}
+	for k, n := range b.Brands {
+		a.Brands[k] += n
+	}
+	for i := range min(len(a.Prices), len(b.Prices)) {
+		a.Prices[i].Count += b.Prices[i].Count
+	}
+	return a
+}
+
+// ShardHealth is a shard's answer to a health check.
+type ShardHealth struct {
+	Shard  int    `json:"shard"`
+	Status string `json:"status"`
+	Ready  bool   `json:"ready"`
+}
+
+// Health checks every shard's /health at once, giving up on those that
+// have not answered when ctx is done.
+func (c *Coordinator) Health(ctx context.Context) []ShardHealth {
+	out := make([]ShardHealth, len(c.shards))
+	var wg sync.WaitGroup
+	for i, base := range c.shards {
+		wg.Go(func() {
+			out[i] = ShardHealth{Shard: i, Status: "unreachable"}
+			httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, base.JoinPath("health").String(), nil)
+			if err != nil {
+				return
+			}
+			resp, err := c.do(httpReq)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Status string `json:"status"`
+			}
+			json.NewDecoder(io.LimitReader(resp.Body, maxErrorBytes)).Decode(&body)
+			out[i].Status = body.Status
+			out[i].Ready = resp.StatusCode == http.StatusOK
+		})
+	}
+	wg.Wait()
+	return out
+}
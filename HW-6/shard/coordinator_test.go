@@ -0,0 +1,253 @@
+package shard_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"product-search/handler"
+	"product-search/model"
+	"product-search/search"
+	"product-search/shard"
+	"product-search/store"
+)
+
+// cluster starts n shards sharing products 1 to size and a coordinator
+// over them. It returns the coordinator's server and the shards'
+// stores.
+func cluster(t *testing.T, n, size int) (*httptest.Server, []store.Store) {
+	t.Helper()
+	cfg := shard.Config{Role: shard.RoleCoordinator}
+	var stores []store.Store
+	for i := range n {
+		s := shard.NewStore(store.New(), shard.Partition{Index: i, Count: n})
+		engine := search.New(s, search.StrategyIndex)
+		for id := 1; id <= size; id++ {
+			s.Put(widget(id)) // refused if another shard owns it
+		}
+		mux := http.NewServeMux()
+		handler.New(s, engine).RegisterRoutes(mux)
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+		u, _ := url.Parse(srv.URL)
+		cfg.Shards = append(cfg.Shards, u)
+		stores = append(stores, s)
+	}
+	mux := http.NewServeMux()
+	handler.NewCoordinator(shard.NewCoordinator(cfg)).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, stores
+}
+
+func widget(id int) model.Product {
+	return model.Product{ID: id, Name: fmt.Sprintf("Widget %d", id), Category: "tools", Brand: "Acme", Price: float64(id)}
+}
+
+func getJSON(t *testing.T, u string, v any) int {
+	t.Helper()
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(v)
+	return resp.StatusCode
+}
+
+// TestCoordinatorPagesWithCursor pages through every shard's products
+// with cursors and checks each product is served once.
+func TestCoordinatorPagesWithCursor(t *testing.T) {
+	srv, _ := cluster(t, 3, 250)
+	seen := make(map[int]int)
+	params := url.Values{"q": {"widget"}, "limit": {"40"}}
+	for page := 0; ; page++ {
+		if page > 20 {
+			t.Fatal("paging did not end")
+		}
+		var res shard.Result
+		if code := getJSON(t, srv.URL+"/products/search?"+params.Encode(), &res); code != http.StatusOK {
+			t.Fatalf("page %d: status %d", page, code)
+		}
+		if res.TotalFound != 250 || res.Shards.Succeeded != 3 {
+			t.Fatalf("page %d: found %d on %d shards", page, res.TotalFound, res.Shards.Succeeded)
+		}
+		for _, h := range res.Products {
+			seen[h.ID]++
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		params.Set("cursor", res.NextCursor)
+	}
+	for id := 1; id <= 250; id++ {
+		if seen[id] != 1 {
+			t.Errorf("product %d served %d times, want once", id, seen[id])
+		}
+	}
+}
+
+func TestCoordinatorRejectsOffsetWithCursor(t *testing.T) {
+	srv, _ := cluster(t, 2, 50)
+	var res shard.Result
+	getJSON(t, srv.URL+"/products/search?q=widget&limit=10", &res)
+	var body struct{ Error string }
+	code := getJSON(t, srv.URL+"/products/search?q=widget&limit=10&offset=10&cursor="+res.NextCursor, &body)
+	if code != http.StatusBadRequest || !strings.Contains(body.Error, "offset and cursor") {
+		t.Fatalf("offset with cursor: status %d, %q; want a 400", code, body.Error)
+	}
+
+	c := shard.NewCoordinator(shard.Config{Shards: []*url.URL{{Scheme: "http", Host: "unused"}}})
+	cursor, _ := search.ParseCursor(res.NextCursor)
+	_, err := c.Search(context.Background(), search.Request{Query: "widget", Offset: 5, Cursor: cursor})
+	if !errors.Is(err, search.ErrInvalidRequest) {
+		t.Fatalf("Search with offset and cursor: %v, want ErrInvalidRequest", err)
+	}
+}
+
+// TestCoordinatorRoutesCreates posts products through the coordinator
+// and checks each lands on, and only on, the shard owning its ID.
+func TestCoordinatorRoutesCreates(t *testing.T) {
+	srv, stores := cluster(t, 3, 30)
+	post := func(body string) (model.Product, int) {
+		resp, err := http.Post(srv.URL+"/products", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var p model.Product
+		json.NewDecoder(resp.Body).Decode(&p)
+		return p, resp.StatusCode
+	}
+
+	var created []int
+	for i := range 9 {
+		p, code := post(fmt.Sprintf(`{"name":"Gadget %d","category":"toys","price":5}`, i))
+		if code != http.StatusCreated {
+			t.Fatalf("create without id: status %d", code)
+		}
+		created = append(created, p.ID)
+	}
+	if p, code := post(`{"id":1000,"name":"Sprocket","category":"parts","price":1}`); code != http.StatusCreated || p.ID != 1000 {
+		t.Fatalf("create with id: status %d, id %d", code, p.ID)
+	}
+	if _, code := post(`{"id":1000,"name":"Sprocket","category":"parts","price":1}`); code != http.StatusConflict {
+		t.Fatalf("duplicate create: status %d, want 409", code)
+	}
+	if _, code := post(`{"id":-1,"name":"Sprocket","category":"parts","price":1}`); code != http.StatusBadRequest {
+		t.Fatalf("negative id: status %d, want 400", code)
+	}
+
+	for _, id := range append(created, 1000) {
+		for i, s := range stores {
+			_, ok := s.Get(id)
+			if owner := shard.Owner(id, len(stores)); ok != (i == owner) {
+				t.Errorf("product %d on shard %d: %v; owner is shard %d", id, i, ok, owner)
+			}
+		}
+		var p model.Product
+		if code := getJSON(t, fmt.Sprintf("%s/products/%d", srv.URL, id), &p); code != http.StatusOK || p.ID != id {
+			t.Errorf("GET product %d through the coordinator: status %d", id, code)
+		}
+	}
+}
+
+// TestShardRejectsForeignWrites writes straight to a shard, bypassing
+// the coordinator.
+func TestShardRejectsForeignWrites(t *testing.T) {
+	p := shard.Partition{Index: 0, Count: 2}
+	s := shard.NewStore(store.New(), p)
+	mux := http.NewServeMux()
+	handler.New(s, search.New(s, search.StrategyIndex)).RegisterRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	foreign := 1
+	for p.Owns(foreign) {
+		foreign++
+	}
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/products/%d", srv.URL, foreign), strings.NewReader(`{"name":"Widget","category":"tools","price":1}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMisdirectedRequest {
+		t.Fatalf("PUT of another shard's product: status %d, want 421", resp.StatusCode)
+	}
+
+	body := fmt.Sprintf("{\"id\":%d,\"name\":\"A\",\"category\":\"c\",\"price\":1}\n{\"name\":\"B\",\"category\":\"c\",\"price\":1}\n", foreign)
+	resp, err = http.Post(srv.URL+"/products/bulk", "application/x-ndjson", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep struct{ Imported, Failed int }
+	json.NewDecoder(resp.Body).Decode(&rep)
+	resp.Body.Close()
+	if rep.Imported != 1 || rep.Failed != 1 || s.Count() != 1 {
+		t.Fatalf("bulk import: %+v, %d stored; want the foreign line rejected", rep, s.Count())
+	}
+}
+
+// TestCoordinatorPartialResult stops one shard and checks the others
+// still answer, marked partial.
+func TestCoordinatorPartialResult(t *testing.T) {
+	cfg := shard.Config{}
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(search.Result{TotalFound: 1, Products: []search.Hit{{Product: widget(1), Score: 1}}})
+	}))
+	defer healthy.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	for _, raw := range []string{healthy.URL, down.URL} {
+		u, _ := url.Parse(raw)
+		cfg.Shards = append(cfg.Shards, u)
+	}
+	res, err := shard.NewCoordinator(cfg).Search(context.Background(), search.Request{Query: "widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Partial || res.Shards.Succeeded != 1 || len(res.Shards.Failures) != 1 || res.Shards.Failures[0].Shard != 1 {
+		t.Fatalf("got partial=%v, shards %+v", res.Partial, res.Shards)
+	}
+}
+
+// TestCoordinatorBoundsHangingShard checks that a shard that never
+// answers is left out of a search without a deadline once the
+// coordinator's timeout passes.
+func TestCoordinatorBoundsHangingShard(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(search.Result{TotalFound: 1, Products: []search.Hit{{Product: widget(1), Score: 1}}})
+	}))
+	defer healthy.Close()
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release // ignores timeout_ms and the client going away
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	cfg := shard.Config{Timeout: 100 * time.Millisecond}
+	for _, raw := range []string{healthy.URL, hanging.URL} {
+		u, _ := url.Parse(raw)
+		cfg.Shards = append(cfg.Shards, u)
+	}
+	start := time.Now()
+	res, err := shard.NewCoordinator(cfg).Search(context.Background(), search.Request{Query: "widget"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("search took %s", elapsed)
+	}
+	if !res.Partial || res.Shards.Succeeded != 1 || len(res.Shards.Failures) != 1 || res.Shards.Failures[0].Shard != 1 {
+		t.Fatalf("got partial=%v, shards %+v", res.Partial, res.Shards)
+	}
+}
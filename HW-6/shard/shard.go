@@ -0,0 +1,143 @@
+// Package shard splits the catalog across instances and gathers their
+// search results.
+//
+// Design decision hidden: How products are assigned to shards, how
+// shard membership is configured, and how a coordinator fans a search
+// out and merges the ranked results. Each shard is an ordinary
+// instance holding part of the catalog; the coordinator talks to it
+// through its public HTTP API, so neither the store nor the search
+// engine knows the catalog is sharded. Membership is static: every
+// instance is told its role and the shard count at startup.
+package shard
+
+import (
+	"fmt"
+	"math/bits"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Role is the part an instance plays in a sharded deployment.
+type Role string
+
+const (
+	// RoleNone is an unsharded instance holding the whole catalog.
+	RoleNone Role = ""
+
+	// RoleShard holds the products of one partition.
+	RoleShard Role = "shard"
+
+	// RoleCoordinator holds no products and serves searches by
+	// querying every shard.
+	RoleCoordinator Role = "coordinator"
+)
+
+// Partition is one of Count equal ranges of the product ID hash
+// space. Hashing first spreads consecutive IDs, and so every seed's
+// variants, evenly over the shards.
+type Partition struct {
+	Index int
+	Count int
+}
+
+// Owner returns the index of the partition, out of count, that holds
+// product id.
+func Owner(id, count int) int {
+	hi, _ := bits.Mul64(mix(uint64(id)), uint64(count))
+	return int(hi)
+}
+
+// Owns reports whether product id belongs to the partition.
+func (p Partition) Owns(id int) bool {
+	return Owner(id, p.Count) == p.Index
+}
+
+// mix is the SplitMix64 finalizer, a cheap bijective hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Config is an instance's place in a sharded deployment.
+type Config struct {
+	Role Role
+
+	// Partition is the part of the catalog a shard holds.
+	Partition Partition
+
+	// Shards are the base URLs of the shards a coordinator queries,
+	// in partition order.
+	Shards []*url.URL
+
+	// APIKey is the key a coordinator presents to the shards when it
+	// searches them. Requests it forwards keep the caller's
+	// credentials instead.
+	APIKey string
+
+	// Timeout bounds a coordinator's search of the shards when the
+	// request sets no deadline of its own. Zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// DefaultTimeout is how long a coordinator waits for the shards to
+// answer a search without a deadline, so a shard that hangs cannot
+// hold the search forever.
+const DefaultTimeout = 10 * time.Second
+
+// ConfigFromEnv reads the sharding configuration:
+//
+//	SHARD_ROLE   "shard" or "coordinator"; unset for an unsharded instance
+//	SHARD_INDEX  a shard's partition, from 0
+//	SHARD_COUNT  the number of shards, for a shard
+//	SHARD_URLS   comma-separated shard base URLs in partition order, for
+//	             a coordinator, e.g. http://shard-0:8080,http://shard-1:8080
+//	SHARD_API_KEY  the API key a coordinator searches shards with, if
+//	             they require authentication
+//	SHARD_TIMEOUT  how long a coordinator waits for the shards to answer
+//	             a search that has no deadline, e.g. 5s; DefaultTimeout
+//	             if unset
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Role: Role(os.Getenv("SHARD_ROLE"))}
+	switch cfg.Role {
+	case RoleNone:
+	case RoleShard:
+		var err error
+		if cfg.Partition.Count, err = strconv.Atoi(os.Getenv("SHARD_COUNT")); err != nil || cfg.Partition.Count < 1 {
+			return Config{}, fmt.Errorf("shard: SHARD_COUNT must be a positive integer, got %q", os.Getenv("SHARD_COUNT"))
+		}
+		if cfg.Partition.Index, err = strconv.Atoi(os.Getenv("SHARD_INDEX")); err != nil || cfg.Partition.Index < 0 || cfg.Partition.Index >= cfg.Partition.Count {
+			return Config{}, fmt.Errorf("shard: SHARD_INDEX must be between 0 and %d, got %q", cfg.Partition.Count-1, os.Getenv("SHARD_INDEX"))
+		}
+	case RoleCoordinator:
+		for raw := range strings.SplitSeq(os.Getenv("SHARD_URLS"), ",") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			u, err := url.Parse(raw)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return Config{}, fmt.Errorf("shard: invalid shard URL %q in SHARD_URLS", raw)
+			}
+			cfg.Shards = append(cfg.Shards, u)
+		}
+		if len(cfg.Shards) == 0 {
+			return Config{}, fmt.Errorf("shard: SHARD_URLS must list the shards for a coordinator")
+		}
+		cfg.APIKey = os.Getenv("SHARD_API_KEY")
+		if v := os.Getenv("SHARD_TIMEOUT"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("shard: SHARD_TIMEOUT must be a positive duration, got %q", v)
+			}
+			cfg.Timeout = d
+		}
+	default:
+		return Config{}, fmt.Errorf("shard: unknown SHARD_ROLE %q (want %q or %q)", cfg.Role, RoleShard, RoleCoordinator)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,93 @@
+package shard
+
+import (
+	"fmt"
+	"sync"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+// NotOwnedError is a write of a product that belongs to another shard.
+type NotOwnedError struct {
+	ID    int
+	Owner int
+}
+
+func (e *NotOwnedError) Error() string {
+	return fmt.Sprintf("product %d belongs to shard %d", e.ID, e.Owner)
+}
+
+// Store keeps a shard's catalog to its partition. Writes of a product
+// another shard owns fail with a *NotOwnedError and leave the store
+// unchanged, so a client that writes to a shard directly cannot place
+// a product where the coordinator will never look for it. Insert gives
+// a product without an ID the next free ID the partition owns, rather
+// than the next free ID. Reads pass through.
+type Store struct {
+	store.Store
+	partition Partition
+	mu        sync.Mutex // serializes ID assignment
+}
+
+// NewStore restricts s to the products partition p owns.
+func NewStore(s store.Store, p Partition) *Store {
+	return &Store{Store: s, partition: p}
+}
+
+// Put adds or replaces a product the partition owns.
+func (s *Store) Put(product model.Product) error {
+	if err := s.check(product.ID); err != nil {
+		return err
+	}
+	return s.Store.Put(product)
+}
+
+// Insert adds a product the partition owns, assigning it an owned ID
+// if it has none.
+func (s *Store) Insert(product model.Product) (model.Product, bool, error) {
+	if product.ID != 0 {
+		if err := s.check(product.ID); err != nil {
+			return product, false, err
+		}
+		return s.Store.Insert(product)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := s.next(s.Store.MaxID()); ; id = s.next(id) {
+		// An explicit ID inserted concurrently may take the candidate;
+		// move on to the next one.
+		product.ID = id
+		created, ok, err := s.Store.Insert(product)
+		if ok || err != nil {
+			return created, ok, err
+		}
+	}
+}
+
+// Replace overwrites a product the partition owns.
+func (s *Store) Replace(product model.Product) (bool, error) {
+	if err := s.check(product.ID); err != nil {
+		return false, err
+	}
+	return s.Store.Replace(product)
+}
+
+// check returns a *NotOwnedError if the partition does not own id.
+func (s *Store) check(id int) error {
+	if owner := Owner(id, s.partition.Count); owner != s.partition.Index {
+		return &NotOwnedError{ID: id, Owner: owner}
+	}
+	return nil
+}
+
+// next returns the lowest ID above after that the partition owns.
+// IDs are hashed evenly, so about one in Count is owned.
+func (s *Store) next(after int) int {
+	id := after + 1
+	for !s.partition.Owns(id) {
+		id++
+	}
+	return id
+}
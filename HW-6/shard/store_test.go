@@ -0,0 +1,91 @@
+package shard
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"product-search/model"
+	"product-search/store"
+)
+
+func TestOwnerSpreadsIDs(t *testing.T) {
+	const count = 4
+	var sizes [count]int
+	for id := 1; id <= 40000; id++ {
+		sizes[Owner(id, count)]++
+	}
+	for i, n := range sizes {
+		if n < 9000 || n > 11000 {
+			t.Errorf("shard %d owns %d of 40000 IDs, want about 10000", i, n)
+		}
+	}
+}
+
+func TestStoreRejectsOtherPartitions(t *testing.T) {
+	p := Partition{Index: 1, Count: 3}
+	s := NewStore(store.New(), p)
+	var foreign int
+	for foreign = 1; p.Owns(foreign); foreign++ {
+	}
+
+	product := model.Product{ID: foreign, Name: "Widget", Category: "tools"}
+	var notOwned *NotOwnedError
+	if err := s.Put(product); !errors.As(err, &notOwned) || notOwned.Owner != Owner(foreign, 3) {
+		t.Errorf("Put of product %d: %v, want a NotOwnedError", foreign, err)
+	}
+	if _, _, err := s.Insert(product); !errors.As(err, &notOwned) {
+		t.Errorf("Insert of product %d: %v, want a NotOwnedError", foreign, err)
+	}
+	if _, err := s.Replace(product); !errors.As(err, &notOwned) {
+		t.Errorf("Replace of product %d: %v, want a NotOwnedError", foreign, err)
+	}
+	if s.Count() != 0 {
+		t.Fatalf("store holds %d products after rejected writes", s.Count())
+	}
+}
+
+// TestStoreAssignsOwnedIDs inserts products without IDs concurrently
+// with explicit ones; every product must get a distinct owned ID.
+func TestStoreAssignsOwnedIDs(t *testing.T) {
+	p := Partition{Index: 2, Count: 3}
+	s := NewStore(store.New(), p)
+	var (
+		mu  sync.Mutex
+		ids = make(map[int]bool)
+		wg  sync.WaitGroup
+	)
+	for range 4 {
+		wg.Go(func() {
+			for range 100 {
+				created, ok, err := s.Insert(model.Product{Name: "Widget", Category: "tools"})
+				if err != nil || !ok {
+					t.Errorf("Insert: %v, %v", ok, err)
+					return
+				}
+				mu.Lock()
+				if ids[created.ID] {
+					t.Errorf("ID %d assigned twice", created.ID)
+				}
+				ids[created.ID] = true
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Go(func() {
+		for id := 1; id <= 600; id++ {
+			if p.Owns(id) {
+				s.Insert(model.Product{ID: id, Name: "Explicit", Category: "tools"})
+			}
+		}
+	})
+	wg.Wait()
+	for id := range ids {
+		if !p.Owns(id) {
+			t.Errorf("assigned ID %d belongs to shard %d", id, Owner(id, 3))
+		}
+	}
+	if len(ids) != 400 {
+		t.Fatalf("%d IDs assigned, want 400", len(ids))
+	}
+}
@@ -0,0 +1,63 @@
+package shard_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestTraceSpansShards searches through the coordinator and checks
+// every shard's server span continues the coordinator's trace under the
+// client span that queried it.
+func TestTraceSpansShards(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	srv, _ := cluster(t, 3, 30)
+	resp, err := http.Get(srv.URL + "/products/search?" + url.Values{"q": {"widget"}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("search: %d", resp.StatusCode)
+	}
+	// The coordinator's server span may end after the response is
+	// read; shutting down waits for its handler to return.
+	srv.Config.Shutdown(context.Background())
+
+	var root sdktrace.ReadOnlySpan
+	queries := make(map[string]bool) // span IDs of shard.query spans
+	var shardSpans []sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		switch s.Name() {
+		case "shard.Search":
+			root = s
+		case "shard.query":
+			queries[s.SpanContext().SpanID().String()] = true
+		case "search.Execute":
+			shardSpans = append(shardSpans, s)
+		}
+	}
+	if root == nil {
+		t.Fatal("no shard.Search span")
+	}
+	if len(queries) != 3 || len(shardSpans) != 3 {
+		t.Fatalf("%d shard.query and %d search.Execute spans, want 3 of each", len(queries), len(shardSpans))
+	}
+	for _, s := range rec.Ended() {
+		if s.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("span %s is in trace %s, not the coordinator's", s.Name(), s.SpanContext().TraceID())
+		}
+		if s.Name() == "GET /products/search" && s.Parent().IsRemote() && !queries[s.Parent().SpanID().String()] {
+			t.Errorf("a shard's server span has parent %s, not a shard.query span", s.Parent().SpanID())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"product-search/model"
+)
+
+// catalog is the bookkeeping shared by every backend: the ordered ID
+// set, secondary indexes and observers. Backends embed it and call
+// applyPut/applyDelete after changing their own records, so the
+// derived structures behave identically whatever holds the products.
+type catalog struct {
+	count atomic.Int64
+
+	// mu serializes writers so the secondary indexes stay consistent
+	// with the records; readers of the indexes share it.
+	mu        sync.RWMutex
+	ids       *idSet // every stored ID, for ordered iteration
+	maxID     int
+	indexes   secondaryIndexes
+	observers []Observer
+}
+
+func newCatalog() catalog {
+	return catalog{ids: newIDSet(), indexes: newSecondaryIndexes()}
+}
+
+// applyPut records that product was written, replacing old if it is
+// non-nil, and notifies observers. Callers must hold c.mu exclusively.
+func (c *catalog) applyPut(old *model.Product, product model.Product) {
+	if old == nil {
+		c.count.Add(1)
+		c.ids.add(product.ID)
+	}
+	c.maxID = max(c.maxID, product.ID)
+	c.indexes.put(old, product)
+	for _, o := range c.observers {
+		o.ProductPut(product)
+	}
+}
+
+// applyDelete records that product was removed and notifies observers.
+// Callers must hold c.mu exclusively.
+func (c *catalog) applyDelete(product model.Product) {
+	c.count.Add(-1)
+	c.ids.remove(product.ID)
+	c.indexes.remove(product)
+	for _, o := range c.observers {
+		o.ProductDeleted(product)
+	}
+}
+
+// Subscribe registers an observer for all subsequent changes. Products
+// already in the store are not replayed; use Iterate to backfill.
+func (c *catalog) Subscribe(o Observer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observers = append(c.observers, o)
+}
+
+// Count returns the total number of products in the store.
+func (c *catalog) Count() int {
+	return int(c.count.Load())
+}
+
+// MaxID returns the highest ID ever stored, or zero.
+func (c *catalog) MaxID() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxID
+}
+
+// iterateBatch is how many IDs Iterate reads per acquisition of the
+// read lock, so long callbacks never hold up writers.
+const iterateBatch = 256
+
+// iterate implements Iterate for a backend, using load to fetch each
+// product. load must do its own locking. ctx is checked once per
+// batch.
+func (c *catalog) iterate(ctx context.Context, startID, maxCount int, load func(int) (model.Product, bool), fn func(model.Product) bool) int {
+	visited := 0
+	batch := make([]int, 0, iterateBatch)
+	for from := startID; visited < maxCount && ctx.Err() == nil; {
+		c.mu.RLock()
+		batch = c.ids.next(from, min(iterateBatch, maxCount-visited), batch[:0])
+		c.mu.RUnlock()
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, id := range batch {
+			p, ok := load(id)
+			if !ok {
+				continue
+			}
+			visited++
+			if !fn(p) {
+				return visited
+			}
+		}
+
+		last := batch[len(batch)-1]
+		if last == math.MaxInt {
+			break
+		}
+		from = last + 1
+	}
+	return visited
+}
+
+// ByCategory returns the sorted IDs of products in the given category.
+// Matching is case-insensitive.
+func (c *catalog) ByCategory(category string) []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lookup(c.indexes.byCategory, category)
+}
+
+// ByBrand returns the sorted IDs of products of the given brand.
+// Matching is case-insensitive.
+func (c *catalog) ByBrand(brand string) []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return lookup(c.indexes.byBrand, brand)
+}
+
+// ByPriceRange returns the sorted IDs of products priced between min
+// and max inclusive.
+func (c *catalog) ByPriceRange(min, max float64) []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.indexes.byPrice.between(min, max)
+}
@@ -0,0 +1,434 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"product-search/model"
+)
+
+// Each log record is laid out as:
+//
+//	[0:4]   payload length (uint32, big endian)
+//	[4:8]   CRC-32 (IEEE) of bytes [8:]
+//	[8]     op: opPut or opDelete
+//	[9:17]  product ID (int64, big endian)
+//	[17:]   payload: JSON-encoded model.Product, empty for deletes
+const (
+	headerSize = 17
+
+	opPut    byte = 1
+	opDelete byte = 2
+
+	// maxPayload rejects absurd lengths read from a damaged header.
+	maxPayload = 16 << 20
+)
+
+// recordRef locates a record in the log.
+type recordRef struct {
+	offset int64
+	size   int64
+}
+
+// DiskStore keeps the catalog in an append-only log file. Only the key
+// directory (ID → record offset) and the secondary indexes live in
+// memory; products are read from the file on demand, so the catalog
+// can outgrow RAM and survives restarts.
+//
+// Writes reach the OS before they return, so they survive a process
+// crash; call Sync to also survive a power loss. Superseded records
+// accumulate until Compact rewrites the log.
+type DiskStore struct {
+	catalog
+	path   string
+	file   *os.File
+	size   int64             // end of the log
+	keydir map[int]recordRef // live record per ID
+	dead   int64             // bytes held by superseded records
+}
+
+var _ Store = (*DiskStore)(nil)
+
+// Open opens or creates the log at path and rebuilds the catalog from
+// it. A torn or corrupt tail, as left by a crash mid-write, is
+// truncated. If more than half the log is superseded records, it is
+// compacted first.
+func Open(path string) (*DiskStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	s := &DiskStore{
+		catalog: newCatalog(),
+		path:    path,
+		file:    f,
+		keydir:  make(map[int]recordRef),
+	}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if s.size == 0 {
+		os.Remove(path + populatedSuffix) // left by a log since deleted
+	}
+	if s.dead > s.size/2 {
+		if err := s.Compact(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// replay scans the log to rebuild the key directory, then loads each
+// live product once to rebuild the catalog's indexes.
+func (s *DiskStore) replay() error {
+	r := bufio.NewReaderSize(s.file, 1<<20)
+	header := make([]byte, headerSize)
+	var payload []byte
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			n := binary.BigEndian.Uint32(header[0:4])
+			if n > maxPayload {
+				err = fmt.Errorf("payload length %d exceeds limit", n)
+			} else {
+				if cap(payload) < int(n) {
+					payload = make([]byte, n)
+				}
+				payload = payload[:n]
+				_, err = io.ReadFull(r, payload)
+			}
+		}
+		if err == nil && checksum(header[8:], payload) != binary.BigEndian.Uint32(header[4:8]) {
+			err = errors.New("checksum mismatch")
+		}
+		if err != nil {
+			log.Printf("store: truncating %s at offset %d: %v", s.path, s.size, err)
+			if err := s.file.Truncate(s.size); err != nil {
+				return fmt.Errorf("store: truncate %s: %w", s.path, err)
+			}
+			break
+		}
+
+		ref := recordRef{offset: s.size, size: headerSize + int64(len(payload))}
+		id := int(int64(binary.BigEndian.Uint64(header[9:17])))
+		s.maxID = max(s.maxID, id) // a deleted ID is still never reused
+		if prev, ok := s.keydir[id]; ok {
+			s.dead += prev.size
+		}
+		switch header[8] {
+		case opPut:
+			s.keydir[id] = ref
+		case opDelete:
+			delete(s.keydir, id)
+			s.dead += ref.size
+		}
+		s.size += ref.size
+	}
+
+	for id, ref := range s.keydir {
+		p, err := s.read(ref)
+		if err != nil {
+			return fmt.Errorf("store: load product %d: %w", id, err)
+		}
+		s.applyPut(nil, p)
+	}
+	log.Printf("store: opened %s with %d products (%d of %d bytes superseded)", s.path, len(s.keydir), s.dead, s.size)
+	return nil
+}
+
+// Put adds or replaces a product in the store.
+func (s *DiskStore) Put(product model.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(product)
+}
+
+// Insert adds a product only if its ID is not already taken.
+func (s *DiskStore) Insert(product model.Product) (model.Product, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if product.ID == 0 {
+		product.ID = s.maxID + 1
+	} else if _, ok := s.keydir[product.ID]; ok {
+		return product, false, nil
+	}
+	if err := s.write(product); err != nil {
+		return product, false, err
+	}
+	return product, true, nil
+}
+
+// Replace overwrites an existing product.
+func (s *DiskStore) Replace(product model.Product) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keydir[product.ID]; !ok {
+		return false, nil
+	}
+	if err := s.write(product); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes a product by ID by appending a tombstone.
+func (s *DiskStore) Delete(id int) (model.Product, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref, ok := s.keydir[id]
+	if !ok {
+		return model.Product{}, false, nil
+	}
+	product, err := s.read(ref)
+	if err != nil {
+		return model.Product{}, false, err
+	}
+	tomb, err := s.append(opDelete, id, nil)
+	if err != nil {
+		return model.Product{}, false, err
+	}
+	delete(s.keydir, id)
+	s.dead += ref.size + tomb.size
+	s.applyDelete(product)
+	return product, true, nil
+}
+
+// write appends product to the log and updates the catalog. Callers
+// must hold s.mu exclusively.
+func (s *DiskStore) write(product model.Product) error {
+	var old *model.Product
+	prevRef, exists := s.keydir[product.ID]
+	if exists {
+		prev, err := s.read(prevRef)
+		if err != nil {
+			return err
+		}
+		old = &prev
+	}
+
+	payload, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("store: encode product %d: %w", product.ID, err)
+	}
+	ref, err := s.append(opPut, product.ID, payload)
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.dead += prevRef.size
+	}
+	s.keydir[product.ID] = ref
+	s.applyPut(old, product)
+	return nil
+}
+
+// append writes one record at the end of the log. A failed write
+// leaves s.size unchanged, so the next record overwrites any partial
+// bytes. Callers must hold s.mu exclusively.
+func (s *DiskStore) append(op byte, id int, payload []byte) (recordRef, error) {
+	buf := record(op, id, payload)
+	if _, err := s.file.WriteAt(buf, s.size); err != nil {
+		return recordRef{}, fmt.Errorf("store: append to %s: %w", s.path, err)
+	}
+	ref := recordRef{offset: s.size, size: int64(len(buf))}
+	s.size += ref.size
+	return ref, nil
+}
+
+// record encodes one log record.
+func record(op byte, id int, payload []byte) []byte {
+	buf := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	buf[8] = op
+	binary.BigEndian.PutUint64(buf[9:17], uint64(int64(id)))
+	copy(buf[headerSize:], payload)
+	binary.BigEndian.PutUint32(buf[4:8], checksum(buf[8:headerSize], payload))
+	return buf
+}
+
+// read loads and verifies the product record at ref. Callers must
+// hold s.mu.
+func (s *DiskStore) read(ref recordRef) (model.Product, error) {
+	buf := make([]byte, ref.size)
+	if _, err := s.file.ReadAt(buf, ref.offset); err != nil {
+		return model.Product{}, fmt.Errorf("store: read %s at offset %d: %w", s.path, ref.offset, err)
+	}
+	if checksum(buf[8:headerSize], buf[headerSize:]) != binary.BigEndian.Uint32(buf[4:8]) {
+		return model.Product{}, fmt.Errorf("store: checksum mismatch in %s at offset %d", s.path, ref.offset)
+	}
+	var p model.Product
+	if err := json.Unmarshal(buf[headerSize:], &p); err != nil {
+		return model.Product{}, fmt.Errorf("store: decode record in %s at offset %d: %w", s.path, ref.offset, err)
+	}
+	return p, nil
+}
+
+// Get retrieves a product by ID, reading it from the log. A record
+// that cannot be read is logged and reported as not found.
+func (s *DiskStore) Get(id int) (model.Product, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ref, ok := s.keydir[id]
+	if !ok {
+		return model.Product{}, false
+	}
+	p, err := s.read(ref)
+	if err != nil {
+		log.Println(err)
+		return model.Product{}, false
+	}
+	return p, true
+}
+
+// Iterate calls fn for products in ascending ID order; see Store.
+func (s *DiskStore) Iterate(ctx context.Context, startID, maxCount int, fn func(model.Product) bool) int {
+	return s.iterate(ctx, startID, maxCount, s.Get, fn)
+}
+
+// Compact rewrites the log with only the live record of each product,
+// in ID order, and atomically replaces the old file with it. If the
+// highest ID ever stored has been deleted, its tombstone is kept so
+// MaxID survives.
+func (s *DiskStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := s.path + ".compact"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("store: compact %s: %w", s.path, err)
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("store: compact %s: %w", s.path, err)
+	}
+
+	w := bufio.NewWriterSize(tmp, 1<<20)
+	keydir := make(map[int]recordRef, len(s.keydir))
+	var size int64
+	for _, id := range s.ids.next(math.MinInt, s.ids.len(), nil) {
+		ref := s.keydir[id]
+		buf := make([]byte, ref.size)
+		if _, err := s.file.ReadAt(buf, ref.offset); err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return fail(err)
+		}
+		keydir[id] = recordRef{offset: size, size: ref.size}
+		size += ref.size
+	}
+	var dead int64
+	if _, live := s.keydir[s.maxID]; !live && s.maxID != 0 {
+		tomb := record(opDelete, s.maxID, nil)
+		if _, err := w.Write(tomb); err != nil {
+			return fail(err)
+		}
+		dead = int64(len(tomb))
+		size += dead
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fail(err)
+	}
+
+	s.file.Close()
+	s.file, s.keydir, s.size, s.dead = tmp, keydir, size, dead
+	return nil
+}
+
+// Sync flushes the log to stable storage.
+func (s *DiskStore) Sync() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.file.Sync()
+}
+
+// populatedSuffix names the file beside the log that records the log
+// holds a complete catalog.
+const populatedSuffix = ".populated"
+
+// MarkPopulated syncs the log and records beside it that it holds a
+// complete catalog of size products, for Populated to report on later
+// runs. A crash before it returns leaves the log unmarked.
+func (s *DiskStore) MarkPopulated(size int) error {
+	if err := s.Sync(); err != nil {
+		return err
+	}
+	path := s.path + populatedSuffix
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(size)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("store: mark %s populated: %w", s.path, err)
+	}
+	if err := syncFile(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("store: mark %s populated: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("store: mark %s populated: %w", s.path, err)
+	}
+	return nil
+}
+
+// Populated returns the catalog size recorded by MarkPopulated, and
+// false if the log was never marked, as after a population that was
+// interrupted.
+func (s *DiskStore) Populated() (size int, ok bool) {
+	b, err := os.ReadFile(s.path + populatedSuffix)
+	if err != nil {
+		return 0, false
+	}
+	size, err = strconv.Atoi(strings.TrimSpace(string(b)))
+	return size, err == nil
+}
+
+// syncFile flushes the file at path to stable storage.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// Close syncs and closes the log. The store must not be used after.
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+// checksum returns the CRC-32 of a record's op, ID and payload.
+func checksum(opAndID, payload []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(opAndID), crc32.IEEETable, payload)
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"product-search/model"
+)
+
+func openTemp(t *testing.T) (*DiskStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "catalog.log")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func reopen(t *testing.T, s *DiskStore, path string) *DiskStore {
+	t.Helper()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestDiskStoreSurvivesReopen(t *testing.T) {
+	s, path := openTemp(t)
+	for id := 1; id <= 10; id++ {
+		if err := s.Put(model.Product{ID: id, Name: fmt.Sprintf("Widget %d", id), Category: "tools", Brand: "Acme"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Put(model.Product{ID: 3, Name: "Gadget", Category: "toys", Brand: "Acme"})
+	s.Delete(7)
+
+	s = reopen(t, s, path)
+	if n := s.Count(); n != 9 {
+		t.Fatalf("count = %d, want 9", n)
+	}
+	if p, ok := s.Get(3); !ok || p.Name != "Gadget" {
+		t.Fatalf("product 3 = %+v, %v; want the update", p, ok)
+	}
+	if _, ok := s.Get(7); ok {
+		t.Fatal("deleted product 7 came back")
+	}
+	if got := s.ByCategory("TOYS"); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("ByCategory(toys) = %v, want [3]", got)
+	}
+}
+
+func TestDiskStoreTruncatesTornTail(t *testing.T) {
+	s, path := openTemp(t)
+	s.Put(model.Product{ID: 1, Name: "Widget"})
+	s.Put(model.Product{ID: 2, Name: "Gadget"})
+	size := s.size
+	s.Close()
+
+	// A crash mid-write leaves part of a record at the end.
+	if err := os.Truncate(path, size-5); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok := s.Get(2); ok || s.Count() != 1 {
+		t.Fatalf("count = %d after a torn write, want 1", s.Count())
+	}
+	if err := s.Put(model.Product{ID: 3, Name: "Sprocket"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := s.Get(3); !ok || p.Name != "Sprocket" {
+		t.Fatalf("write after truncation read back as %+v, %v", p, ok)
+	}
+}
+
+// TestDiskStoreTruncatesCorruptRecord flips a byte inside a record
+// and checks replay keeps the records before it and drops the rest,
+// since nothing after a damaged record can be trusted to be aligned.
+func TestDiskStoreTruncatesCorruptRecord(t *testing.T) {
+	s, path := openTemp(t)
+	s.Put(model.Product{ID: 1, Name: "Widget"})
+	second := s.size
+	s.Put(model.Product{ID: 2, Name: "Gadget"})
+	s.Put(model.Product{ID: 3, Name: "Sprocket"})
+	s.Close()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	f.ReadAt(buf, second+headerSize+2)
+	buf[0] ^= 0xff
+	f.WriteAt(buf, second+headerSize+2)
+	f.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Count() != 1 || s.size != second {
+		t.Fatalf("count = %d, log %d bytes after corruption; want 1 product in %d bytes", s.Count(), s.size, second)
+	}
+	if p, ok := s.Get(1); !ok || p.Name != "Widget" {
+		t.Fatalf("product 1 = %+v, %v", p, ok)
+	}
+}
+
+// TestDiskStoreKeepsMaxID checks that an ID freed by deleting the
+// newest product is not handed out again after a reopen or compaction.
+func TestDiskStoreKeepsMaxID(t *testing.T) {
+	s, path := openTemp(t)
+	for id := 1; id <= 5; id++ {
+		s.Put(model.Product{ID: id, Name: "Widget"})
+	}
+	s.Delete(5)
+	s.Delete(4)
+	s = reopen(t, s, path)
+	if s.MaxID() != 5 {
+		t.Fatalf("MaxID() = %d after reopen, want 5", s.MaxID())
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	s = reopen(t, s, path)
+	if p, ok, err := s.Insert(model.Product{Name: "Gadget"}); err != nil || !ok || p.ID != 6 {
+		t.Fatalf("Insert after compaction gave ID %d, %v, %v; want 6", p.ID, ok, err)
+	}
+}
+
+// TestDiskStoreCompactsOnOpen checks that a log mostly made of
+// superseded records is rewritten when it is opened.
+func TestDiskStoreCompactsOnOpen(t *testing.T) {
+	s, path := openTemp(t)
+	for round := range 4 {
+		for id := 1; id <= 20; id++ {
+			s.Put(model.Product{ID: id, Name: fmt.Sprintf("Widget %d.%d", id, round)})
+		}
+	}
+	before := s.size
+	s = reopen(t, s, path)
+	if s.dead != 0 || s.size >= before/2 {
+		t.Fatalf("log is %d bytes with %d dead after reopening %d bytes; want it compacted", s.size, s.dead, before)
+	}
+	if p, _ := s.Get(20); p.Name != "Widget 20.3" {
+		t.Fatalf("product 20 = %q, want the last version", p.Name)
+	}
+}
+
+func TestDiskStorePopulatedMarker(t *testing.T) {
+	s, path := openTemp(t)
+	s.Put(model.Product{ID: 1, Name: "Widget"})
+	if _, ok := s.Populated(); ok {
+		t.Fatal("a fresh log reports a complete catalog")
+	}
+
+	// An interrupted population leaves products but no marker.
+	s = reopen(t, s, path)
+	if _, ok := s.Populated(); ok {
+		t.Fatal("an unmarked log reports a complete catalog")
+	}
+	if err := s.MarkPopulated(500); err != nil {
+		t.Fatal(err)
+	}
+	s = reopen(t, s, path)
+	if size, ok := s.Populated(); !ok || size != 500 {
+		t.Fatalf("Populated() = %d, %v; want 500, true", size, ok)
+	}
+
+	// A marker outliving its log does not vouch for a new one.
+	s.Close()
+	os.Remove(path)
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok := s.Populated(); ok {
+		t.Fatal("a new log inherited the old log's marker")
+	}
+}
+
+func TestDiskStoreCompactKeepsLiveRecords(t *testing.T) {
+	s, path := openTemp(t)
+	for round := range 3 {
+		for id := 1; id <= 50; id++ {
+			s.Put(model.Product{ID: id, Name: fmt.Sprintf("Widget %d.%d", id, round)})
+		}
+	}
+	s.Delete(10)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if s.dead != 0 {
+		t.Fatalf("%d dead bytes after compaction", s.dead)
+	}
+	s = reopen(t, s, path)
+	if s.Count() != 49 {
+		t.Fatalf("count = %d, want 49", s.Count())
+	}
+	if p, _ := s.Get(50); p.Name != "Widget 50.2" {
+		t.Fatalf("product 50 = %q, want the last version", p.Name)
+	}
+}
+
+// TestDiskStoreConcurrentAccess runs writers, readers and iterators
+// together; run it with -race.
+func TestDiskStoreConcurrentAccess(t *testing.T) {
+	s, _ := openTemp(t)
+	defer s.Close()
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Go(func() {
+			for i := range 200 {
+				id := w*1000 + i
+				s.Put(model.Product{ID: id, Name: "Widget", Brand: "Acme"})
+				if i%3 == 0 {
+					s.Delete(id)
+				}
+			}
+		})
+	}
+	for range 4 {
+		wg.Go(func() {
+			for i := range 200 {
+				if p, ok := s.Get(i); ok && p.ID != i {
+					t.Errorf("Get(%d) returned product %d", i, p.ID)
+				}
+				s.Iterate(context.Background(), 0, 50, func(model.Product) bool { return true })
+			}
+		})
+	}
+	wg.Wait()
+	if want := 4 * (200 - 67); s.Count() != want {
+		t.Fatalf("count = %d, want %d", s.Count(), want)
+	}
+	if got := len(s.ByBrand("acme")); got != s.Count() {
+		t.Fatalf("brand index holds %d products, store %d", got, s.Count())
+	}
+}
@@ -0,0 +1,96 @@
+package store
+
+import "sort"
+
+// bucketShift sets the bucket width: 1<<bucketShift consecutive IDs
+// share a bucket.
+const bucketShift = 9
+
+// idSet is an ordered set of product IDs. IDs are grouped into
+// fixed-width buckets so an out-of-order insert only shifts one small
+// sorted slice rather than the whole set.
+type idSet struct {
+	keys    []int         // sorted bucket keys
+	buckets map[int][]int // bucket key → sorted IDs
+	n       int
+}
+
+func newIDSet() *idSet {
+	return &idSet{buckets: make(map[int][]int)}
+}
+
+// bucketOf returns the bucket key for id. The arithmetic shift rounds
+// toward -∞, so negative IDs stay ordered.
+func bucketOf(id int) int {
+	return id >> bucketShift
+}
+
+// add inserts id and reports whether it was absent.
+func (s *idSet) add(id int) bool {
+	key := bucketOf(id)
+	ids, ok := s.buckets[key]
+	if !ok {
+		i := sort.SearchInts(s.keys, key)
+		s.keys = append(s.keys, 0)
+		copy(s.keys[i+1:], s.keys[i:])
+		s.keys[i] = key
+	}
+	i := sort.SearchInts(ids, id)
+	if i < len(ids) && ids[i] == id {
+		return false
+	}
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	s.buckets[key] = ids
+	s.n++
+	return true
+}
+
+// remove deletes id and reports whether it was present.
+func (s *idSet) remove(id int) bool {
+	key := bucketOf(id)
+	ids := s.buckets[key]
+	i := sort.SearchInts(ids, id)
+	if i == len(ids) || ids[i] != id {
+		return false
+	}
+	ids = append(ids[:i], ids[i+1:]...)
+	if len(ids) == 0 {
+		delete(s.buckets, key)
+		k := sort.SearchInts(s.keys, key)
+		s.keys = append(s.keys[:k], s.keys[k+1:]...)
+	} else {
+		s.buckets[key] = ids
+	}
+	s.n--
+	return true
+}
+
+// len returns the number of IDs in the set.
+func (s *idSet) len() int {
+	return s.n
+}
+
+// slice returns a sorted copy of the IDs in the set.
+func (s *idSet) slice() []int {
+	out := make([]int, 0, s.n)
+	for _, key := range s.keys {
+		out = append(out, s.buckets[key]...)
+	}
+	return out
+}
+
+// next appends to buf up to n IDs greater than or equal to from, in
+// ascending order, and returns the extended slice.
+func (s *idSet) next(from, n int, buf []int) []int {
+	k := sort.SearchInts(s.keys, bucketOf(from))
+	for ; k < len(s.keys) && n > 0; k++ {
+		ids := s.buckets[s.keys[k]]
+		i := sort.SearchInts(ids, from)
+		take := min(n, len(ids)-i)
+		buf = append(buf, ids[i:i+take]...)
+		n -= take
+	}
+	return buf
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// TestIDSetMatchesSortedSlice applies random adds and removes, across
+// bucket boundaries and negative IDs, to an idSet and a plain sorted
+// slice and checks they agree.
+func TestIDSetMatchesSortedSlice(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	s := newIDSet()
+	var want []int
+	for range 20000 {
+		id := r.IntN(5000) - 1000
+		i, found := slices.BinarySearch(want, id)
+		if r.IntN(3) == 0 {
+			if s.remove(id) != found {
+				t.Fatalf("remove(%d) disagreed with presence %v", id, found)
+			}
+			if found {
+				want = slices.Delete(want, i, i+1)
+			}
+		} else {
+			if s.add(id) == found {
+				t.Fatalf("add(%d) disagreed with presence %v", id, found)
+			}
+			if !found {
+				want = slices.Insert(want, i, id)
+			}
+		}
+	}
+	if s.len() != len(want) || !slices.Equal(s.slice(), want) {
+		t.Fatalf("set holds %d IDs, want %d", s.len(), len(want))
+	}
+
+	for _, from := range []int{math.MinInt, -1000, -1, 0, 511, 512, 3999, 4000} {
+		i, _ := slices.BinarySearch(want, from)
+		end := min(i+700, len(want))
+		if got := s.next(from, 700, nil); !slices.Equal(got, want[i:end]) {
+			t.Errorf("next(%d, 700) returned %d IDs that disagree with the %d expected", from, len(got), end-i)
+		}
+	}
+	if got := s.next(math.MaxInt, 10, nil); len(got) != 0 {
+		t.Errorf("next past the end = %v", got)
+	}
+}
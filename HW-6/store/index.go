@@ -0,0 +1,122 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"product-search/model"
+)
+
+// secondaryIndexes map attribute values to the products that carry
+// them, so filtered queries avoid a full scan. Keys are lower-cased
+// to make lookups case-insensitive. Callers must hold the store's
+// mutex: exclusively to write, shared to read.
+type secondaryIndexes struct {
+	byCategory map[string]*idSet
+	byBrand    map[string]*idSet
+	byPrice    priceIndex
+}
+
+func newSecondaryIndexes() secondaryIndexes {
+	return secondaryIndexes{
+		byCategory: make(map[string]*idSet),
+		byBrand:    make(map[string]*idSet),
+		byPrice:    priceIndex{prices: make(map[int]float64)},
+	}
+}
+
+// put indexes p, first removing old if the product is being replaced.
+func (x *secondaryIndexes) put(old *model.Product, p model.Product) {
+	if old != nil {
+		x.remove(*old)
+	}
+	addTo(x.byCategory, p.Category, p.ID)
+	addTo(x.byBrand, p.Brand, p.ID)
+	x.byPrice.put(p.ID, p.Price)
+}
+
+// remove drops p from every index.
+func (x *secondaryIndexes) remove(p model.Product) {
+	removeFrom(x.byCategory, p.Category, p.ID)
+	removeFrom(x.byBrand, p.Brand, p.ID)
+	x.byPrice.remove(p.ID)
+}
+
+func addTo(index map[string]*idSet, value string, id int) {
+	key := strings.ToLower(value)
+	set, ok := index[key]
+	if !ok {
+		set = newIDSet()
+		index[key] = set
+	}
+	set.add(id)
+}
+
+func removeFrom(index map[string]*idSet, value string, id int) {
+	key := strings.ToLower(value)
+	if set, ok := index[key]; ok {
+		set.remove(id)
+		if set.len() == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+func lookup(index map[string]*idSet, value string) []int {
+	if set, ok := index[strings.ToLower(value)]; ok {
+		return set.slice()
+	}
+	return nil
+}
+
+// pricedID pairs a product ID with its price for range queries.
+type pricedID struct {
+	price float64
+	id    int
+}
+
+// priceIndex answers price range queries from a price-sorted slice.
+// Writes only invalidate the slice; it is rebuilt on the next query,
+// so bulk loads don't pay for keeping it sorted.
+type priceIndex struct {
+	prices map[int]float64
+
+	mu     sync.Mutex // serializes rebuilds among concurrent readers
+	sorted []pricedID // nil when stale
+}
+
+func (pi *priceIndex) put(id int, price float64) {
+	pi.prices[id] = price
+	pi.sorted = nil
+}
+
+func (pi *priceIndex) remove(id int) {
+	delete(pi.prices, id)
+	pi.sorted = nil
+}
+
+// between returns the sorted IDs of products priced in [lo, hi].
+func (pi *priceIndex) between(lo, hi float64) []int {
+	pi.mu.Lock()
+	if pi.sorted == nil {
+		pi.sorted = make([]pricedID, 0, len(pi.prices))
+		for id, price := range pi.prices {
+			pi.sorted = append(pi.sorted, pricedID{price: price, id: id})
+		}
+		sort.Slice(pi.sorted, func(i, j int) bool { return pi.sorted[i].price < pi.sorted[j].price })
+	}
+	sorted := pi.sorted
+	pi.mu.Unlock()
+
+	start := sort.Search(len(sorted), func(i int) bool { return sorted[i].price >= lo })
+	var ids []int
+	for _, e := range sorted[start:] {
+		if e.price > hi {
+			break
+		}
+		ids = append(ids, e.id)
+	}
+	sort.Ints(ids)
+	return ids
+}
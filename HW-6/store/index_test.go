@@ -0,0 +1,41 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"product-search/model"
+)
+
+func TestSecondaryIndexes(t *testing.T) {
+	s := New()
+	for _, p := range []model.Product{
+		{ID: 1, Category: "Tools", Brand: "Acme", Price: 10},
+		{ID: 2, Category: "tools", Brand: "Bolt", Price: 20},
+		{ID: 3, Category: "Toys", Brand: "ACME", Price: 20},
+		{ID: 4, Category: "Toys", Brand: "Crest", Price: 35.5},
+	} {
+		s.Put(p)
+	}
+	check := func(what string, got, want []int) {
+		t.Helper()
+		if !slices.Equal(got, want) {
+			t.Errorf("%s = %v, want %v", what, got, want)
+		}
+	}
+	check("ByCategory(TOOLS)", s.ByCategory("TOOLS"), []int{1, 2})
+	check("ByBrand(acme)", s.ByBrand("acme"), []int{1, 3})
+	check("ByBrand(none)", s.ByBrand("none"), nil)
+	check("ByPriceRange(20, 35.5)", s.ByPriceRange(20, 35.5), []int{2, 3, 4})
+	check("ByPriceRange(11, 19)", s.ByPriceRange(11, 19), nil)
+
+	// A product moves between sets when rewritten and leaves them
+	// when deleted.
+	s.Put(model.Product{ID: 1, Category: "Toys", Brand: "Acme", Price: 50})
+	s.Delete(3)
+	check("ByCategory(tools) after writes", s.ByCategory("tools"), []int{2})
+	check("ByCategory(toys) after writes", s.ByCategory("toys"), []int{1, 4})
+	check("ByBrand(acme) after writes", s.ByBrand("acme"), []int{1})
+	check("ByPriceRange(0, 20) after writes", s.ByPriceRange(0, 20), []int{2})
+	check("ByPriceRange(40, 60) after writes", s.ByPriceRange(40, 60), []int{1})
+}
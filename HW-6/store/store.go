@@ -1,35 +1,162 @@
 // Package store provides thread-safe product storage.
 //
 // Design decision hidden: The storage mechanism and concurrency strategy.
-// Currently uses sync.Map for lock-free concurrent reads and atomic.Int64
-// for the count so both operations are safe for concurrent callers. Could
-// be replaced with a slice, database, Redis, or any other backing store
-// without affecting consumers. The iteration order and access patterns
-// are encapsulated here.
+// Consumers depend only on the Store interface. Two backends exist:
+//
+//	ProductStore → in memory, sync.Map for lock-free concurrent reads
+//	               (the default)
+//	DiskStore    → append-only log file with an in-memory key directory,
+//	               so the catalog survives restarts and product bodies
+//	               need not fit in RAM
+//
+// The iteration order (ascending ID, over an ordered ID set so sparse
+// IDs are fine) and access patterns are encapsulated here, as are the
+// secondary indexes (category, brand, price) that let filtered queries
+// avoid a full scan. Both backends share that bookkeeping (catalog), so
+// they only differ in where product records live.
 package store
 
 import (
+	"context"
 	"sync"
-	"sync/atomic"
 
 	"product-search/model"
 )
 
+// Store is a product catalog. Implementations are safe for concurrent
+// use. Write methods return an error only if the backend failed to
+// persist the change, in which case the catalog is left unchanged.
+type Store interface {
+	// Put adds or replaces a product.
+	Put(product model.Product) error
+
+	// Insert adds a product only if its ID is not already taken. A
+	// zero ID is replaced with one past the highest ID in use. It
+	// returns the stored product and false if the ID was taken.
+	Insert(product model.Product) (model.Product, bool, error)
+
+	// Replace overwrites an existing product. It returns false,
+	// leaving the store unchanged, if no product has that ID.
+	Replace(product model.Product) (bool, error)
+
+	// Delete removes a product by ID. Returns the removed product and
+	// whether it was found.
+	Delete(id int) (model.Product, bool, error)
+
+	// Get retrieves a product by ID. Returns the product and whether
+	// it was found.
+	Get(id int) (model.Product, bool)
+
+	// Count returns the total number of products in the store.
+	Count() int
+
+	// MaxID returns the highest ID ever stored, or zero. Deleting that
+	// product does not lower it, so Insert never reuses an ID.
+	MaxID() int
+
+	// Iterate calls fn for products with ID >= startID in ascending ID
+	// order, up to maxCount products. IDs need not be dense. It
+	// returns the number of products visited. fn returns true to
+	// continue, false to stop. Iteration also stops once ctx is done.
+	//
+	// Iteration tolerates concurrent writes: products deleted before
+	// they are reached are skipped, and products added past the
+	// current position may or may not be visited.
+	Iterate(ctx context.Context, startID, maxCount int, fn func(model.Product) bool) int
+
+	// ByCategory, ByBrand and ByPriceRange return the sorted IDs of
+	// matching products from the secondary indexes. Category and
+	// brand match case-insensitively; the price range is inclusive.
+	ByCategory(category string) []int
+	ByBrand(brand string) []int
+	ByPriceRange(min, max float64) []int
+
+	// Subscribe registers an observer for all subsequent changes.
+	// Products already in the store are not replayed; use Iterate to
+	// backfill.
+	Subscribe(o Observer)
+}
+
+// Observer is notified after every change to the catalog. Derived
+// structures (e.g. search indexes) subscribe so they stay in sync with
+// the catalog without the store knowing what they are.
+//
+// Callbacks run with the store's write lock held, so each observer
+// sees changes in the order they were applied. They must not call
+// back into the store.
+type Observer interface {
+	ProductPut(product model.Product)
+	ProductDeleted(product model.Product)
+}
+
 // ProductStore manages the product catalog in memory.
 type ProductStore struct {
-	data  sync.Map
-	count atomic.Int64
+	catalog
+	data sync.Map
 }
 
+var _ Store = (*ProductStore)(nil)
+
 // New creates an empty ProductStore.
 func New() *ProductStore {
-	return &ProductStore{}
+	return &ProductStore{catalog: newCatalog()}
 }
 
-// Put adds or updates a product in the store.
-func (s *ProductStore) Put(product model.Product) {
-	s.data.Store(product.ID, product)
-	s.count.Add(1)
+// Put adds or replaces a product in the store. It never fails.
+func (s *ProductStore) Put(product model.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(product)
+	return nil
+}
+
+// Insert adds a product only if its ID is not already taken.
+func (s *ProductStore) Insert(product model.Product) (model.Product, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if product.ID == 0 {
+		product.ID = s.maxID + 1
+	} else if _, ok := s.data.Load(product.ID); ok {
+		return product, false, nil
+	}
+	s.write(product)
+	return product, true, nil
+}
+
+// Replace overwrites an existing product.
+func (s *ProductStore) Replace(product model.Product) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Load(product.ID); !ok {
+		return false, nil
+	}
+	s.write(product)
+	return true, nil
+}
+
+// Delete removes a product by ID.
+func (s *ProductStore) Delete(id int) (model.Product, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.data.LoadAndDelete(id)
+	if !ok {
+		return model.Product{}, false, nil
+	}
+	product := val.(model.Product)
+	s.applyDelete(product)
+	return product, true, nil
+}
+
+// write stores product and updates the catalog. Callers must hold
+// s.mu exclusively.
+func (s *ProductStore) write(product model.Product) {
+	var old *model.Product
+	if val, loaded := s.data.Swap(product.ID, product); loaded {
+		prev := val.(model.Product)
+		old = &prev
+	}
+	s.applyPut(old, product)
 }
 
 // Get retrieves a product by ID. Returns the product and whether it was found.
@@ -41,26 +168,7 @@ func (s *ProductStore) Get(id int) (model.Product, bool) {
 	return val.(model.Product), true
 }
 
-// Count returns the total number of products in the store.
-func (s *ProductStore) Count() int {
-	return int(s.count.Load())
-}
-
-// Iterate calls the given function for products starting at startID,
-// up to maxCount products. It returns the number of products visited.
-// The callback receives each product and returns true to continue, false to stop.
-func (s *ProductStore) Iterate(startID, maxCount int, fn func(model.Product) bool) int {
-	total := int(s.count.Load())
-	visited := 0
-	for id := startID; id <= total && visited < maxCount; id++ {
-		val, ok := s.data.Load(id)
-		if !ok {
-			continue
-		}
-		visited++
-		if !fn(val.(model.Product)) {
-			break
-		}
-	}
-	return visited
+// Iterate calls fn for products in ascending ID order; see Store.
+func (s *ProductStore) Iterate(ctx context.Context, startID, maxCount int, fn func(model.Product) bool) int {
+	return s.iterate(ctx, startID, maxCount, s.Get, fn)
 }
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"product-search/model"
+)
+
+func TestProductStore(t *testing.T) {
+	s := New()
+	for _, id := range []int{40, 3, 1000, 7} {
+		s.Put(model.Product{ID: id, Name: "Widget"})
+	}
+	s.Put(model.Product{ID: 7, Name: "Gadget"}) // replaces
+	if s.Count() != 4 || s.MaxID() != 1000 {
+		t.Fatalf("count %d, max ID %d; want 4 and 1000", s.Count(), s.MaxID())
+	}
+
+	p, ok, err := s.Delete(1000)
+	if err != nil || !ok || p.ID != 1000 {
+		t.Fatalf("Delete(1000) = %+v, %v, %v", p, ok, err)
+	}
+	if _, ok, _ := s.Delete(1000); ok {
+		t.Fatal("deleted product 1000 twice")
+	}
+	if s.Count() != 3 || s.MaxID() != 1000 {
+		t.Fatalf("after delete: count %d, max ID %d; want 3 and 1000", s.Count(), s.MaxID())
+	}
+
+	// A deleted ID is never handed out again.
+	created, ok, _ := s.Insert(model.Product{Name: "Sprocket"})
+	if !ok || created.ID != 1001 {
+		t.Fatalf("Insert gave ID %d, want 1001", created.ID)
+	}
+	if _, ok, _ := s.Insert(model.Product{ID: 3, Name: "Clash"}); ok {
+		t.Fatal("Insert took an ID in use")
+	}
+	if ok, _ := s.Replace(model.Product{ID: 5, Name: "Nothing"}); ok {
+		t.Fatal("Replace created a product")
+	}
+
+	var ids []int
+	s.Iterate(context.Background(), math.MinInt, math.MaxInt, func(p model.Product) bool {
+		ids = append(ids, p.ID)
+		return true
+	})
+	if !slices.Equal(ids, []int{3, 7, 40, 1001}) {
+		t.Fatalf("iterated %v, want [3 7 40 1001]", ids)
+	}
+	if n := s.Iterate(context.Background(), 8, 2, func(model.Product) bool { return true }); n != 2 {
+		t.Fatalf("Iterate from 8 visited %d, want 2", n)
+	}
+}
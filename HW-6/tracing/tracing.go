@@ -0,0 +1,119 @@
+// Package tracing records where requests spend their time as
+// OpenTelemetry traces.
+//
+// Design decision hidden: Where spans go and which traces are kept.
+// The other packages start spans through the OpenTelemetry API alone
+// and never see an exporter or a sampler; Start installs the SDK
+// behind that API, exporting over OTLP to a collector or as JSON lines
+// to a file for local testing. Trace context travels between services
+// in W3C traceparent headers.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+)
+
+// serviceName names the service in its spans unless OTEL_SERVICE_NAME
+// is set.
+const serviceName = "product-search"
+
+// Exporters that Start can send spans to.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Config selects where spans go.
+type Config struct {
+	// Exporter is ExporterNone, ExporterOTLP or ExporterFile.
+	Exporter string
+
+	// File is the path spans are appended to by ExporterFile.
+	File string
+}
+
+// ConfigFromEnv reads the tracing configuration:
+//
+//	OTEL_TRACES_EXPORTER  "otlp", "file" or "none" (the default)
+//	TRACE_FILE            the file for "file", default traces.jsonl
+//
+// The OTLP exporter reads the standard OTEL_EXPORTER_OTLP_* variables,
+// by default sending to http://localhost:4318, and the SDK reads
+// OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES and OTEL_TRACES_SAMPLER,
+// by default sampling every trace a caller's traceparent has not
+// already decided against.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Exporter: os.Getenv("OTEL_TRACES_EXPORTER"), File: os.Getenv("TRACE_FILE")}
+	switch cfg.Exporter {
+	case "":
+		cfg.Exporter = ExporterNone
+	case ExporterNone, ExporterOTLP:
+	case ExporterFile:
+		if cfg.File == "" {
+			cfg.File = "traces.jsonl"
+		}
+	default:
+		return Config{}, fmt.Errorf("tracing: unknown OTEL_TRACES_EXPORTER %q (want %q, %q or %q)", cfg.Exporter, ExporterOTLP, ExporterFile, ExporterNone)
+	}
+	return cfg, nil
+}
+
+// Start installs the W3C trace context propagator and, unless cfg's
+// exporter is ExporterNone, a tracer provider exporting to it. Without
+// a provider, spans cost next to nothing and traceparent headers are
+// still passed on. The returned function flushes buffered spans and
+// stops exporting; call it on shutdown.
+func Start(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch cfg.Exporter {
+	case ExporterNone:
+		return noop, nil
+	case ExporterOTLP:
+		if exporter, err = otlptracehttp.New(ctx); err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+	case ExporterFile:
+		if file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(file)); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err == nil {
+		res, err = resource.Merge(res, resource.Environment())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
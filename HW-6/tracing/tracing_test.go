@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		exporter, file string
+		want           Config
+		err            bool
+	}{
+		{"", "", Config{Exporter: ExporterNone}, false},
+		{"otlp", "", Config{Exporter: ExporterOTLP}, false},
+		{"file", "", Config{Exporter: ExporterFile, File: "traces.jsonl"}, false},
+		{"file", "/tmp/t.jsonl", Config{Exporter: ExporterFile, File: "/tmp/t.jsonl"}, false},
+		{"zipkin", "", Config{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("OTEL_TRACES_EXPORTER", tt.exporter)
+		t.Setenv("TRACE_FILE", tt.file)
+		got, err := ConfigFromEnv()
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("OTEL_TRACES_EXPORTER=%q: %+v, %v", tt.exporter, got, err)
+		}
+	}
+}
+
+// TestStartExportsToFile traces a parent and child span and checks
+// shutdown flushes both to the file, in one trace.
+func TestStartExportsToFile(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "tracing-test")
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Start(context.Background(), Config{Exporter: ExporterFile, File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := otel.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, want := range []string{`"Name":"parent"`, `"Name":"child"`, parent.SpanContext().TraceID().String(), "tracing-test"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace file lacks %s", want)
+		}
+	}
+}